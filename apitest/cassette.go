@@ -0,0 +1,290 @@
+// Package apitest provides helpers to test code that calls APIs with the stdutil request functions.
+package apitest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/eaglebush/stdutil"
+)
+
+// Mode is the mode of a recorder
+type Mode int
+
+// Recorder modes
+const (
+	ModeReplay Mode = iota // Replay the interactions of the cassette, failing on unknown requests
+	ModeRecord             // Send the requests and record the interactions in the cassette
+	ModeAuto               // Replay if the cassette file exists, record otherwise
+)
+
+// Match selects the parts of a request compared when replaying
+type Match uint
+
+// Request parts
+const (
+	MatchMethod Match = 1 << iota // Match the method
+	MatchPath                     // Match the scheme, host and path
+	MatchQuery                    // Match the query, regardless of the order of the parameters
+	MatchBody                     // Match the body
+
+	MatchDefault = MatchMethod | MatchPath | MatchQuery
+)
+
+// ErrNoInteraction is returned when replaying a request that is not in the cassette
+var ErrNoInteraction = errors.New(`apitest: no recorded interaction matches the request`)
+
+type (
+	// Cassette is the list of recorded interactions stored in a JSON file
+	Cassette struct {
+		Interactions []Interaction `json:"interactions"`
+	}
+	// Interaction is a recorded request and its response
+	Interaction struct {
+		Request  RecordedRequest  `json:"request"`
+		Response RecordedResponse `json:"response"`
+	}
+	// RecordedRequest is a recorded request
+	RecordedRequest struct {
+		Method       string      `json:"method"`
+		URL          string      `json:"url"`
+		Header       http.Header `json:"header,omitempty"`
+		Body         string      `json:"body,omitempty"`
+		BodyEncoding string      `json:"body_encoding,omitempty"` // "base64" if the body is not valid UTF-8
+	}
+	// RecordedResponse is a recorded response
+	RecordedResponse struct {
+		StatusCode   int         `json:"status_code"`
+		Header       http.Header `json:"header,omitempty"`
+		Body         string      `json:"body,omitempty"`
+		BodyEncoding string      `json:"body_encoding,omitempty"` // "base64" if the body is not valid UTF-8
+	}
+	// Recorder is a transport that records interactions to a cassette file, or replays them.
+	// Use it with the Transport request option:
+	//
+	//	rec, _ := apitest.NewRecorder("testdata/users.json", apitest.ModeAuto)
+	//	defer rec.Save()
+	//	res := stdutil.ReadApi[[]User](url, stdutil.Transport(rec))
+	//
+	// Identical requests are replayed in the order they were recorded.
+	Recorder struct {
+		Path          string            // Path of the cassette file
+		Mode          Mode              // Mode of the recorder. ModeAuto is resolved when the recorder is created
+		Match         Match             // Parts of the request compared when replaying. Default: MatchDefault
+		Transport     http.RoundTripper // Transport of the recorded requests. Default: stdutil.DefaultTransport()
+		RedactHeaders []string          // Request headers not recorded. Default: Authorization and Cookie
+		cassette      Cassette
+		used          []bool
+		mu            sync.Mutex
+	}
+)
+
+// NewRecorder creates a recorder of a cassette file. The cassette is loaded when replaying.
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{
+		Path:  path,
+		Mode:  mode,
+		Match: MatchDefault,
+	}
+	if mode == ModeAuto {
+		r.Mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			r.Mode = ModeReplay
+		}
+	}
+	if r.Mode != ModeReplay {
+		return r, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &r.cassette); err != nil {
+		return nil, fmt.Errorf("apitest: invalid cassette %s: %w", path, err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// Interactions returns the interactions of the cassette
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.cassette.Interactions...)
+}
+
+// Save writes the recorded interactions to the cassette file. It does nothing when replaying.
+func (r *Recorder) Save() error {
+	if r.Mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.Path, b, 0o644)
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.Mode == ModeReplay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body)
+}
+
+// record sends the request and records its interaction
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	rt := r.Transport
+	if rt == nil {
+		rt = stdutil.DefaultTransport()
+	}
+	// The request of the caller is left untouched, and a copy is sent with the body read
+	out := req.Clone(req.Context())
+	if body != nil {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	resp, err := rt.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	rbody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	redact := r.RedactHeaders
+	if redact == nil {
+		redact = []string{"Authorization", "Cookie"}
+	}
+	hdr := req.Header.Clone()
+	for _, h := range redact {
+		hdr.Del(h)
+	}
+	it := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: hdr,
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+		},
+	}
+	it.Request.Body, it.Request.BodyEncoding = encodeBody(body)
+	it.Response.Body, it.Response.BodyEncoding = encodeBody(rbody)
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, it)
+	r.mu.Unlock()
+	resp.Body = io.NopCloser(bytes.NewReader(rbody))
+	resp.Request = req
+	return resp, nil
+}
+
+// replay returns the response of the first unused interaction matching the request
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, it := range r.cassette.Interactions {
+		if r.used[i] || !r.matches(req, body, it.Request) {
+			continue
+		}
+		rbody, err := decodeBody(it.Response.Body, it.Response.BodyEncoding)
+		if err != nil {
+			return nil, err
+		}
+		r.used[i] = true
+		hdr := it.Response.Header.Clone()
+		if hdr == nil {
+			hdr = make(http.Header)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", it.Response.StatusCode, http.StatusText(it.Response.StatusCode)),
+			StatusCode:    it.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        hdr,
+			Body:          io.NopCloser(bytes.NewReader(rbody)),
+			ContentLength: int64(len(rbody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, req.URL)
+}
+
+// matches checks if a request matches a recorded request
+func (r *Recorder) matches(req *http.Request, body []byte, rec RecordedRequest) bool {
+	m := r.Match
+	if m == 0 {
+		m = MatchDefault
+	}
+	if m&MatchMethod != 0 && !strings.EqualFold(req.Method, rec.Method) {
+		return false
+	}
+	ru, err := req.URL.Parse(rec.URL)
+	if err != nil {
+		return false
+	}
+	if m&MatchPath != 0 && (req.URL.Scheme != ru.Scheme || req.URL.Host != ru.Host || req.URL.Path != ru.Path) {
+		return false
+	}
+	if m&MatchQuery != 0 && req.URL.Query().Encode() != ru.Query().Encode() {
+		return false
+	}
+	if m&MatchBody != 0 {
+		rb, err := decodeBody(rec.Body, rec.BodyEncoding)
+		if err != nil || !bytes.Equal(body, rb) {
+			return false
+		}
+	}
+	return true
+}
+
+// readRequestBody reads and closes the body of a request
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// encodeBody returns a body as a string, in base64 if it is not valid UTF-8
+func encodeBody(b []byte) (string, string) {
+	if utf8.Valid(b) {
+		return string(b), ""
+	}
+	return base64.StdEncoding.EncodeToString(b), "base64"
+}
+
+// decodeBody returns the bytes of a recorded body
+func decodeBody(s string, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return []byte(s), nil
+}
@@ -0,0 +1,101 @@
+package apitest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/eaglebush/stdutil"
+)
+
+func TestRecorder(t *testing.T) {
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusCreated)
+		}
+		w.Write([]byte(`{"status":"OK","data":{"id":` + strconv.Itoa(int(n)) + `,"name":"` + r.URL.Query().Get("name") + `"}}`))
+	}))
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, err := NewRecorder(path, ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode != ModeRecord {
+		t.Fatalf("expected record mode, got %d", rec.Mode)
+	}
+	opts := []stdutil.RequestOption{stdutil.Transport(rec), stdutil.BearerToken("secret"), stdutil.Compressed(true)}
+	r1 := stdutil.ReadApi[item](srv.URL+"/items?name=a&x=1", opts...)
+	r2 := stdutil.CreateApi[item](srv.URL+"/items?name=b", item{Name: "b"}, opts...)
+	if !r1.OK() || !r2.OK() {
+		t.Fatalf("unexpected results %s %s", r1.Status, r2.Status)
+	}
+	if err = rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+	if strings.Contains(rec.Interactions()[0].Request.Header.Get("Authorization"), "secret") {
+		t.Error("expected the authorization header to be redacted")
+	}
+
+	// Replay without the server, with the query in a different order
+	rec, err = NewRecorder(path, ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Match = MatchDefault | MatchBody
+	opts[0] = stdutil.Transport(rec)
+	p1 := stdutil.ReadApi[item](srv.URL+"/items?x=1&name=a", opts...)
+	p2 := stdutil.CreateApi[item](srv.URL+"/items?name=b", item{Name: "b"}, opts...)
+	if p1.Data != r1.Data || p2.Data != r2.Data {
+		t.Errorf("expected %v and %v, got %v and %v", r1.Data, r2.Data, p1.Data, p2.Data)
+	}
+
+	// Interactions are replayed once, and bodies must match
+	_, err = stdutil.ExecuteApi("GET", srv.URL+"/items?name=a&x=1", nil, false, nil, 5, stdutil.Transport(rec))
+	if !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("expected ErrNoInteraction, got %v", err)
+	}
+	rec, _ = NewRecorder(path, ModeReplay)
+	rec.Match = MatchDefault | MatchBody
+	p2 = stdutil.CreateApi[item](srv.URL+"/items?name=b", item{Name: "c"}, stdutil.Transport(rec))
+	if p2.OK() {
+		t.Error("expected a different body not to match")
+	}
+}
+
+func TestRecorderRequestUntouched(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+	}))
+	defer srv.Close()
+
+	rec := &Recorder{Mode: ModeRecord}
+	req, _ := http.NewRequest("POST", srv.URL, strings.NewReader("payload"))
+	body := req.Body
+	resp, err := rec.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if req.Body != body || resp.Request != req {
+		t.Error("expected the request of the caller to be left untouched")
+	}
+	if got != "payload" || rec.Interactions()[0].Request.Body != "payload" {
+		t.Errorf("unexpected bodies %q %q", got, rec.Interactions()[0].Request.Body)
+	}
+}
@@ -0,0 +1,214 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eaglebush/stdutil"
+	"github.com/gorilla/mux"
+)
+
+type (
+	// Reply is a canned response of a route of a mock server
+	Reply struct {
+		Status int           // HTTP status code. Default: 200
+		Header http.Header   // Headers of the response
+		Body   any           // Value sent as JSON, such as a stdutil.ResultAny. Byte slices are sent as is
+		Delay  time.Duration // Time to wait before responding. It ends early if the client goes away
+		Drop   bool          // Close the connection without a response, to simulate a network failure
+	}
+	// Request is a request received by a mock server
+	Request struct {
+		Method    string              // Method of the request
+		URL       string              // URL of the request, with the query
+		Header    http.Header         // Headers of the request
+		Body      []byte              // Body of the request
+		Route     string              // Path template of the matched route
+		RouteVars map[string]string   // Variables of the path template
+		Vars      stdutil.RequestVars // Request variables, decoded as handlers do with stdutil.GetRequestVarsOnly
+	}
+	// Server is a mock API server that responds with the result envelope
+	// read by the stdutil request functions, and records the requests it receives.
+	//
+	//	srv := apitest.NewServer()
+	//	defer srv.Close()
+	//	srv.Handle("GET", "/users/{id}", apitest.ReplyOK(User{ID: 1}))
+	//	res := stdutil.ReadApi[User](srv.URL + "/users/1")
+	Server struct {
+		*httptest.Server
+		router   *mux.Router
+		requests []Request
+		mu       sync.Mutex
+	}
+	// route is a registered route of a mock server
+	route struct {
+		replies []Reply
+		calls   int
+		mu      sync.Mutex
+	}
+)
+
+// NewServer starts a mock API server. Unregistered routes respond with 404 Not Found.
+func NewServer() *Server {
+	s := &Server{
+		router: mux.NewRouter(),
+	}
+	s.router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.record(r)
+		writeReply(w, r, ReplyError(http.StatusNotFound, "no route for "+r.Method+" "+r.URL.Path))
+	})
+	s.Server = httptest.NewServer(s.router)
+	return s
+}
+
+// Handle registers the replies of a method and a path template. The path can have
+// variables, as in "/users/{id}". Replies are sent in turn, and the last one is repeated,
+// so failures can be injected before a success:
+//
+//	srv.Handle("GET", "/users", apitest.Reply{Status: 503}, apitest.ReplyOK(users))
+func (s *Server) Handle(method string, path string, replies ...Reply) {
+	if len(replies) == 0 {
+		replies = []Reply{{}}
+	}
+	rt := &route{replies: replies}
+	s.router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		s.record(r)
+		writeReply(w, r, rt.next())
+	}).Methods(strings.ToUpper(method))
+}
+
+// Requests returns the requests received by the server
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest returns the last request received by the server
+func (s *Server) LastRequest() (Request, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return Request{}, false
+	}
+	return s.requests[len(s.requests)-1], true
+}
+
+// Reset clears the received requests
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// ReplyOK returns a reply of an OK result with the data and information messages
+func ReplyOK[T any](data T, msgs ...string) Reply {
+	res := stdutil.ResultAny[T]{
+		Result: stdutil.InitResult(stdutil.NameValue[string]{Name: "status", Value: string(stdutil.OK)}),
+		Data:   data,
+	}
+	for _, m := range msgs {
+		res.AddInfo(m)
+	}
+	return Reply{Body: res}
+}
+
+// ReplyError returns a reply of an exception result with the status code and error messages
+func ReplyError(status int, msgs ...string) Reply {
+	res := stdutil.InitResult()
+	for _, m := range msgs {
+		res.AddError(m)
+	}
+	return Reply{
+		Status: status,
+		Body:   res,
+	}
+}
+
+// next returns the reply of the next call of the route
+func (rt *route) next() Reply {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	i := rt.calls
+	if i >= len(rt.replies) {
+		i = len(rt.replies) - 1
+	}
+	rt.calls++
+	return rt.replies[i]
+}
+
+// record records a received request
+func (s *Server) record(r *http.Request) {
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+		r.Body.Close()
+	}
+	req := Request{
+		Method:    r.Method,
+		URL:       r.URL.String(),
+		Header:    r.Header.Clone(),
+		Body:      body,
+		RouteVars: mux.Vars(r),
+	}
+	if cr := mux.CurrentRoute(r); cr != nil {
+		req.Route, _ = cr.GetPathTemplate()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		req.Vars = stdutil.GetRequestVarsOnly(r)
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+}
+
+// writeReply writes a reply
+func writeReply(w http.ResponseWriter, r *http.Request, rp Reply) {
+	if rp.Delay > 0 {
+		tmr := time.NewTimer(rp.Delay)
+		defer tmr.Stop()
+		select {
+		case <-tmr.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if rp.Drop {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+	for k, v := range rp.Header {
+		w.Header()[k] = v
+	}
+	status := rp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if rp.Body == nil {
+		w.WriteHeader(status)
+		return
+	}
+	b, ok := rp.Body.([]byte)
+	if !ok {
+		var err error
+		if b, err = json.Marshal(rp.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+	}
+	w.WriteHeader(status)
+	w.Write(b)
+}
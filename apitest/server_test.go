@@ -0,0 +1,67 @@
+package apitest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eaglebush/stdutil"
+)
+
+func TestServer(t *testing.T) {
+	type user struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	srv := NewServer()
+	defer srv.Close()
+	srv.Handle("GET", "/users/{id}", ReplyOK(user{ID: 7, Name: "ann"}, "found"))
+	srv.Handle("POST", "/users", Reply{Status: http.StatusServiceUnavailable}, ReplyOK(user{ID: 8, Name: "bob"}))
+	srv.Handle("DELETE", "/users/{id}", ReplyError(http.StatusConflict, "user is in use"))
+	srv.Handle("GET", "/slow", Reply{Delay: time.Second})
+	srv.Handle("GET", "/drop", Reply{Drop: true})
+
+	res := stdutil.ReadApi[user](srv.URL + "/users/7?expand=roles")
+	if !res.OK() || res.Data.Name != "ann" || !strings.Contains(res.MessagesToString(), "found") {
+		t.Errorf("unexpected result %s %+v %s", res.Status, res.Data, res.MessagesToString())
+	}
+	req, _ := srv.LastRequest()
+	if req.Route != "/users/{id}" || req.RouteVars["id"] != "7" || req.Vars.Variables.QueryString.Pair["expand"] != "roles" {
+		t.Errorf("unexpected request %+v", req)
+	}
+
+	// Failures are injected before the success
+	cres := stdutil.CreateApi[user](srv.URL+"/users", user{Name: "bob"}, stdutil.Retry(stdutil.RetryPolicy{
+		BaseBackoff:        time.Millisecond,
+		RetryNonIdempotent: true,
+	}))
+	if !cres.OK() || cres.Data.ID != 8 {
+		t.Errorf("unexpected result %s %+v", cres.Status, cres.Data)
+	}
+	req, _ = srv.LastRequest()
+	if !req.Vars.HasBody || string(req.Vars.Body) != `{"id":0,"name":"bob"}` {
+		t.Errorf("unexpected body %s", req.Vars.Body)
+	}
+
+	dres := stdutil.DeleteApi[any](srv.URL + "/users/7")
+	if dres.OK() || !strings.Contains(dres.MessagesToString(), "user is in use") {
+		t.Errorf("unexpected result %s %s", dres.Status, dres.MessagesToString())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if sres := stdutil.ReadApiCtx[any](ctx, srv.URL+"/slow"); sres.OK() {
+		t.Error("expected a time out")
+	}
+	if _, err := stdutil.ExecuteApi("GET", srv.URL+"/drop", nil, false, nil, 5); err == nil {
+		t.Error("expected a network error")
+	}
+	if nres := stdutil.ReadApi[any](srv.URL + "/none"); nres.OK() {
+		t.Error("expected not found")
+	}
+	if n := len(srv.Requests()); n != 7 {
+		t.Errorf("expected 7 requests, got %d", n)
+	}
+}
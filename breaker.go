@@ -0,0 +1,218 @@
+package stdutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit of a circuit breaker
+type CircuitState int
+
+// Circuit states
+const (
+	CircuitClosed   CircuitState = iota // Requests are sent and failures are counted
+	CircuitOpen                         // Requests fail fast with ErrCircuitOpen until the cool down ends
+	CircuitHalfOpen                     // A limited number of trial requests are sent to probe the host
+)
+
+// ErrCircuitOpen is returned when a request is not sent because the circuit of its host is open
+var ErrCircuitOpen = errors.New(`circuit open`)
+
+// errRequestNotSent releases an allowed request that failed before it was sent
+var errRequestNotSent = errors.New(`request not sent`)
+
+type (
+	// CircuitBreaker stops sending requests to a host after consecutive failures.
+	//
+	// Each host has its own circuit. A circuit opens after FailureThreshold consecutive
+	// failures, and requests to the host fail with ErrCircuitOpen. After the cool down,
+	// the circuit is half open and lets trial requests through: a success closes it,
+	// while a failure opens it again.
+	//
+	// A circuit breaker is safe for concurrent use and is meant to be shared by the calls
+	// to the same services, through the CircuitBreak option.
+	CircuitBreaker struct {
+		FailureThreshold int                                       // Consecutive failures that open the circuit. Default: 5
+		CoolDown         time.Duration                             // Time the circuit stays open before trial requests are let through. Default: 30s
+		HalfOpenRequests int                                       // Number of concurrent trial requests while half open. Default: 1
+		IsFailure        func(resp *http.Response, err error) bool // Checks if a response or error is a failure. Default: errors and 5xx status codes
+		mu               sync.Mutex
+		circuits         map[string]*circuit
+	}
+	// circuit is the state of the circuit of a host
+	circuit struct {
+		state    CircuitState
+		failures int       // consecutive failures
+		openedAt time.Time // time the circuit was opened
+		trials   int       // trial requests in flight while half open
+	}
+)
+
+// NewCircuitBreaker creates a circuit breaker that opens after a number of
+// consecutive failures and stays open for the cool down
+func NewCircuitBreaker(failureThreshold int, coolDown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		CoolDown:         coolDown,
+	}
+}
+
+// CircuitBreak sets the circuit breaker of the request as an option
+//
+// This is used with <REST verb>Api functions
+func CircuitBreak(cb *CircuitBreaker) RequestOption {
+	return func(rp *RequestParam) error {
+		rp.Breaker = cb
+		return nil
+	}
+}
+
+// String returns the name of the circuit state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// State returns the state of the circuit of a host. A host with no requests yet is closed.
+func (cb *CircuitBreaker) State(host string) CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	c, ok := cb.circuits[host]
+	if !ok {
+		return CircuitClosed
+	}
+	cb.cool(c)
+	return c.state
+}
+
+// States returns the state of the circuits of all hosts that received requests, for health checks
+func (cb *CircuitBreaker) States() map[string]CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	states := make(map[string]CircuitState, len(cb.circuits))
+	for h, c := range cb.circuits {
+		cb.cool(c)
+		states[h] = c.state
+	}
+	return states
+}
+
+// Reset closes the circuit of a host. An empty host closes all circuits.
+func (cb *CircuitBreaker) Reset(host string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if host == "" {
+		cb.circuits = nil
+		return
+	}
+	delete(cb.circuits, host)
+}
+
+// allow checks if a request can be sent to the host. If it can,
+// the returned function must be called with the outcome of the request.
+func (cb *CircuitBreaker) allow(host string) (func(resp *http.Response, err error), error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.circuits == nil {
+		cb.circuits = make(map[string]*circuit)
+	}
+	c, ok := cb.circuits[host]
+	if !ok {
+		c = &circuit{}
+		cb.circuits[host] = c
+	}
+	cb.cool(c)
+	trial := false
+	switch c.state {
+	case CircuitOpen:
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+	case CircuitHalfOpen:
+		maxTrials := cb.HalfOpenRequests
+		if maxTrials <= 0 {
+			maxTrials = 1
+		}
+		if c.trials >= maxTrials {
+			return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+		}
+		c.trials++
+		trial = true
+	}
+	return func(resp *http.Response, err error) {
+		cb.record(c, trial, resp, err)
+	}, nil
+}
+
+// record updates the circuit with the outcome of a request
+func (cb *CircuitBreaker) record(c *circuit, trial bool, resp *http.Response, err error) {
+	// A request canceled by the caller, or not sent at all, says nothing about the host
+	if errors.Is(err, ErrRequestCanceled) || errors.Is(err, context.Canceled) || errors.Is(err, errRequestNotSent) {
+		cb.mu.Lock()
+		defer cb.mu.Unlock()
+		if trial && c.trials > 0 {
+			c.trials--
+		}
+		return
+	}
+	isFailure := cb.IsFailure
+	if isFailure == nil {
+		isFailure = isCircuitFailure
+	}
+	failed := isFailure(resp, err)
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if trial && c.trials > 0 {
+		c.trials--
+	}
+	if !failed {
+		if c.state == CircuitHalfOpen && !trial {
+			return
+		}
+		c.state = CircuitClosed
+		c.failures = 0
+		return
+	}
+	c.failures++
+	threshold := cb.FailureThreshold
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if c.state == CircuitHalfOpen || c.failures >= threshold {
+		c.state = CircuitOpen
+		c.openedAt = time.Now()
+		c.trials = 0
+	}
+}
+
+// cool moves an open circuit to half open once the cool down has passed
+func (cb *CircuitBreaker) cool(c *circuit) {
+	if c.state != CircuitOpen {
+		return
+	}
+	coolDown := cb.CoolDown
+	if coolDown <= 0 {
+		coolDown = 30 * time.Second
+	}
+	if time.Since(c.openedAt) >= coolDown {
+		c.state = CircuitHalfOpen
+		c.trials = 0
+	}
+}
+
+// isCircuitFailure checks if a request failed with an error or a 5xx status code
+func isCircuitFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp != nil && resp.StatusCode >= 500
+}
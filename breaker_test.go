@@ -0,0 +1,79 @@
+package stdutil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var hits, healthy int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"OK"}`))
+	}))
+	defer srv.Close()
+	su, _ := url.Parse(srv.URL)
+	host := su.Host
+
+	cb := NewCircuitBreaker(2, 50*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if res := ReadApi[any](srv.URL, CircuitBreak(cb)); res.OK() {
+			t.Fatal("expected a failure")
+		}
+	}
+	if st := cb.State(host); st != CircuitOpen {
+		t.Fatalf("expected open circuit, got %s", st)
+	}
+
+	// An open circuit fails fast without hitting the server
+	res := ReadApi[any](srv.URL, CircuitBreak(cb))
+	if res.OK() || !strings.Contains(res.MessagesToString(), ErrCircuitOpen.Error()) {
+		t.Errorf("expected a circuit open message, got %s", res.MessagesToString())
+	}
+	if _, err := ExecuteApi("GET", srv.URL, nil, false, nil, 5, CircuitBreak(cb)); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("expected 2 hits, got %d", n)
+	}
+
+	// An open circuit does not use rate limit quota
+	rl := &RateLimiter{Rate: 0.001, Burst: 1, FailFast: true}
+	if _, err := ExecuteApi("GET", srv.URL, nil, false, nil, 5, CircuitBreak(cb), RateLimit(rl)); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+	if err := rl.Wait(context.Background(), host); err != nil {
+		t.Errorf("expected the rate limit quota to be left, got %v", err)
+	}
+
+	// A failed trial request opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	if st := cb.State(host); st != CircuitHalfOpen {
+		t.Fatalf("expected half-open circuit, got %s", st)
+	}
+	ReadApi[any](srv.URL, CircuitBreak(cb))
+	if st := cb.State(host); st != CircuitOpen {
+		t.Fatalf("expected open circuit, got %s", st)
+	}
+
+	// A successful trial request closes the circuit
+	time.Sleep(60 * time.Millisecond)
+	atomic.StoreInt32(&healthy, 1)
+	if res := ReadApi[any](srv.URL, CircuitBreak(cb)); !res.OK() {
+		t.Fatalf("unexpected result %s %s", res.Status, res.MessagesToString())
+	}
+	if st := cb.States()[host]; st != CircuitClosed {
+		t.Errorf("expected closed circuit, got %s", st)
+	}
+}
@@ -0,0 +1,344 @@
+package stdutil
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// CacheEntry is a response stored in a cache
+	CacheEntry struct {
+		Body         []byte      // Decoded body of the response
+		Header       http.Header // Headers of the response
+		ETag         string      // Entity tag of the response, sent back in If-None-Match
+		LastModified string      // Last modification time of the response, sent back in If-Modified-Since
+		StoredAt     time.Time   // Time the response was stored or last revalidated
+		Expires      time.Time   // Time until the response is fresh and used without a request
+		Request      http.Header // Request headers listed in the Vary header of the response
+	}
+	// CacheStore stores cached responses by key. It must be safe for concurrent use.
+	CacheStore interface {
+		Get(key string) (CacheEntry, bool)
+		Set(key string, entry CacheEntry)
+		Delete(key string)
+	}
+	// LRUCache is an in-memory cache store that evicts the least recently used
+	// entries when it holds too many entries or too many bytes of response bodies
+	LRUCache struct {
+		maxEntries int
+		maxBytes   int
+		size       int
+		ll         *list.List
+		items      map[string]*list.Element
+		mu         sync.Mutex
+	}
+	// lruItem is an entry of an LRU cache
+	lruItem struct {
+		key   string
+		entry CacheEntry
+	}
+	// cacheControl holds the Cache-Control directives of a response
+	cacheControl struct {
+		noStore bool
+		noCache bool
+		private bool
+		maxAge  int
+		hasAge  bool
+	}
+)
+
+var (
+	defaultCache     *LRUCache // cache store used when the Cache option has no store
+	defaultCacheOnce sync.Once
+)
+
+// NewLRUCache creates an in-memory LRU cache store. A limit of zero or less means no limit.
+func NewLRUCache(maxEntries int, maxBytes int) *LRUCache {
+	return &LRUCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Cache caches the responses of GET requests in a store as an option.
+// If the store is nil, a shared in-memory LRU cache of 1000 entries and 32 MB is used.
+//
+// Fresh responses, as set by the Cache-Control max-age directive or the Expires header, are
+// used without a request. Stale responses with an ETag or Last-Modified header are revalidated
+// with a conditional request, and a 304 Not Modified response returns the stored response.
+// Responses with the no-store directive are not cached, nor are responses with the private
+// directive in the shared store. Requests are cached apart by their credentials, sent in the
+// Authorization or Cookie headers, and by the request headers listed in the Vary header
+// of the response.
+//
+// This is used with <REST verb>Api functions
+func Cache(store CacheStore) RequestOption {
+	return func(rp *RequestParam) error {
+		if store == nil {
+			defaultCacheOnce.Do(func() {
+				defaultCache = NewLRUCache(1000, 32<<20)
+			})
+			store = defaultCache
+		}
+		rp.Cache = store
+		rp.sharedCache = store == CacheStore(defaultCache)
+		return nil
+	}
+}
+
+// Get returns the entry of a key and marks it as recently used
+func (c *LRUCache) Get(key string) (CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+// Set stores the entry of a key, evicting the least recently used entries if needed
+func (c *LRUCache) Set(key string, entry CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxBytes > 0 && len(entry.Body) > c.maxBytes {
+		c.remove(key)
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		it := el.Value.(*lruItem)
+		c.size += len(entry.Body) - len(it.entry.Body)
+		it.entry = entry
+		c.ll.MoveToFront(el)
+	} else {
+		c.items[key] = c.ll.PushFront(&lruItem{key: key, entry: entry})
+		c.size += len(entry.Body)
+	}
+	for (c.maxEntries > 0 && c.ll.Len() > c.maxEntries) || (c.maxBytes > 0 && c.size > c.maxBytes) {
+		c.remove(c.ll.Back().Value.(*lruItem).key)
+	}
+}
+
+// Delete removes the entry of a key
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(key)
+}
+
+// Len returns the number of entries in the cache
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// remove removes the entry of a key. The lock must be held.
+func (c *LRUCache) remove(key string) {
+	el, ok := c.items[key]
+	if !ok {
+		return
+	}
+	c.ll.Remove(el)
+	delete(c.items, key)
+	c.size -= len(el.Value.(*lruItem).entry.Body)
+}
+
+// Fresh checks if the entry can be used without a request
+func (e CacheEntry) Fresh(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+// sendCachedRequest sends a GET request through the cache of the request parameters.
+// Bodies are copied in and out of the store, so that callers cannot change stored responses.
+func sendCachedRequest(ctx context.Context, endPoint string, rp *RequestParam) ([]byte, error) {
+	key := rp.cacheKey(endPoint)
+	entry, ok := rp.Cache.Get(key)
+	ok = ok && rp.varyMatches(entry)
+	if ok && entry.Fresh(time.Now()) {
+		rp.cacheHit = true
+		return bytes.Clone(entry.Body), nil
+	}
+
+	// Revalidate a stale entry with a conditional request
+	crp := *rp
+	if ok && (entry.ETag != "" || entry.LastModified != "") {
+		crp.HeaderValues = make(map[string][]string, len(rp.HeaderValues)+2)
+		for k, v := range rp.HeaderValues {
+			crp.HeaderValues[k] = v
+		}
+		if entry.ETag != "" {
+			crp.HeaderValues["If-None-Match"] = []string{entry.ETag}
+		}
+		if entry.LastModified != "" {
+			crp.HeaderValues["If-Modified-Since"] = []string{entry.LastModified}
+		}
+	}
+	resp, err := doRequest(ctx, "GET", endPoint, nil, &crp)
+	if err != nil {
+		var herr *HTTPError
+		if ok && errors.As(err, &herr) && herr.StatusCode == http.StatusNotModified {
+			entry.StoredAt = time.Now()
+			entry.Expires = cacheExpiry(herr.Header, entry.StoredAt)
+			rp.Cache.Set(key, entry)
+			rp.cacheHit = true
+			return bytes.Clone(entry.Body), nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := readResponseBody(resp)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	cc := parseCacheControl(resp.Header.Get("Cache-Control"))
+	vary, varies := rp.varyHeaders(resp.Header)
+	if cc.noStore || (cc.private && rp.sharedCache) || !varies {
+		rp.Cache.Delete(key)
+		return data, nil
+	}
+	ne := CacheEntry{
+		Body:         bytes.Clone(data),
+		Header:       resp.Header.Clone(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		StoredAt:     time.Now(),
+		Request:      vary,
+	}
+	ne.Expires = cacheExpiry(resp.Header, ne.StoredAt)
+	// A response that cannot be revalidated nor reused replaces the stored entry too
+	if ne.ETag == "" && ne.LastModified == "" && !ne.Fresh(ne.StoredAt) {
+		rp.Cache.Delete(key)
+		return data, nil
+	}
+	rp.Cache.Set(key, ne)
+	return data, nil
+}
+
+// cacheKey returns the cache key of a request. Requests with different credentials, sent
+// in the Authorization or Cookie headers, are cached apart without keeping the credentials in the key.
+func (rp *RequestParam) cacheKey(endPoint string) string {
+	auth := rp.headerValues("Authorization")
+	cookies := rp.headerValues("Cookie")
+	if len(auth) == 0 && len(cookies) == 0 {
+		return "GET " + endPoint
+	}
+	sum := sha256.Sum256([]byte(strings.Join(auth, ",") + "\x00" + strings.Join(cookies, "; ")))
+	return "GET " + endPoint + " " + hex.EncodeToString(sum[:8])
+}
+
+// headerValues returns the values of a request header set by the caller. HeaderValues take
+// precedence over Headers, except for cookies, which are sent from both.
+func (rp *RequestParam) headerValues(name string) []string {
+	var values []string
+	if rp.Mutex != nil {
+		rp.Mutex.RLock()
+	}
+	for k, v := range rp.Headers {
+		if strings.EqualFold(k, name) {
+			values = []string{v}
+		}
+	}
+	if rp.Mutex != nil {
+		rp.Mutex.RUnlock()
+	}
+	cookie := strings.EqualFold(name, "Cookie")
+	for k, v := range rp.HeaderValues {
+		if !strings.EqualFold(k, name) {
+			continue
+		}
+		if cookie {
+			values = append(values, v...)
+			continue
+		}
+		values = v
+	}
+	return values
+}
+
+// varyHeaders returns the request headers listed in the Vary header of a response.
+// It returns false if the response varies on anything else ("*").
+func (rp *RequestParam) varyHeaders(header http.Header) (http.Header, bool) {
+	var vary http.Header
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			switch name {
+			case "":
+				continue
+			case "*":
+				return nil, false
+			}
+			if vary == nil {
+				vary = make(http.Header)
+			}
+			vary[name] = rp.headerValues(name)
+		}
+	}
+	return vary, true
+}
+
+// varyMatches checks if the request headers listed in the Vary header of a stored response
+// are the same as those of the request
+func (rp *RequestParam) varyMatches(entry CacheEntry) bool {
+	for name, values := range entry.Request {
+		if !slices.Equal(values, rp.headerValues(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheExpiry returns the time until a response is fresh from its headers
+func cacheExpiry(header http.Header, now time.Time) time.Time {
+	cc := parseCacheControl(header.Get("Cache-Control"))
+	if cc.noCache || cc.noStore {
+		return now
+	}
+	if cc.hasAge {
+		age, _ := strconv.Atoi(strings.TrimSpace(header.Get("Age")))
+		return now.Add(time.Duration(cc.maxAge-age) * time.Second)
+	}
+	if exp := header.Get("Expires"); exp != "" {
+		// An invalid Expires header, such as "0", means already expired
+		if at, err := http.ParseTime(exp); err == nil {
+			return at
+		}
+	}
+	return now
+}
+
+// parseCacheControl parses the directives of a Cache-Control header
+func parseCacheControl(value string) cacheControl {
+	cc := cacheControl{}
+	for _, d := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(d), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			cc.noStore = true
+		case "no-cache":
+			cc.noCache = true
+		case "private":
+			cc.private = true
+		case "max-age":
+			if n, err := strconv.Atoi(strings.Trim(arg, `"`)); err == nil {
+				cc.maxAge = n
+				cc.hasAge = true
+			}
+		}
+	}
+	return cc
+}
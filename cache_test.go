@@ -0,0 +1,184 @@
+package stdutil
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	var hits, notModified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/changed":
+			// The first version has an ETag, the next ones neither validators nor a lifetime
+			n := atomic.LoadInt32(&hits)
+			if n == 1 {
+				w.Header().Set("ETag", `"v1"`)
+			} else if n > 2 && r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			fmt.Fprintf(w, `{"status":"OK","data":%d}`, atomic.LoadInt32(&hits))
+			return
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("ETag", `"v1"`)
+		}
+		fmt.Fprintf(w, `{"status":"OK","data":%q}`, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	store := NewLRUCache(10, 0)
+	for _, tc := range []struct {
+		path      string
+		wantHits  int32
+		fromCache bool
+	}{
+		{"/fresh", 1, true},
+		{"/etag", 2, true},
+		{"/nostore", 2, false},
+	} {
+		atomic.StoreInt32(&hits, 0)
+		var rd ResultData
+		for i := 0; i < 2; i++ {
+			rd = ExecuteJsonApi("GET", srv.URL+tc.path, nil, false, nil, 5, nil, Cache(store))
+			if !rd.OK() || string(rd.Data) != fmt.Sprintf("%q", tc.path) {
+				t.Fatalf("%s: unexpected result %s %s", tc.path, rd.Status, rd.Data)
+			}
+		}
+		if n := atomic.LoadInt32(&hits); n != tc.wantHits {
+			t.Errorf("%s: expected %d hits, got %d", tc.path, tc.wantHits, n)
+		}
+		if rd.FromCache != tc.fromCache {
+			t.Errorf("%s: expected from cache %v, got %v", tc.path, tc.fromCache, rd.FromCache)
+		}
+	}
+	// Changing returned data does not change the stored response
+	for i := 0; i < 2; i++ {
+		rd := ExecuteJsonApi("GET", srv.URL+"/fresh", nil, false, nil, 5, nil, Cache(store))
+		if string(rd.Data) != `"/fresh"` {
+			t.Fatalf("expected the stored response, got %s", rd.Data)
+		}
+		for j := range rd.Data {
+			rd.Data[j] = 'x'
+		}
+	}
+
+	if notModified != 1 {
+		t.Errorf("expected 1 not modified response, got %d", notModified)
+	}
+
+	// Typed reads are cached as well
+	atomic.StoreInt32(&hits, 0)
+	if res := ReadApi[string](srv.URL+"/fresh", Cache(store)); !res.OK() || res.Data != "/fresh" {
+		t.Errorf("unexpected result %s %s", res.Status, res.Data)
+	}
+	if hits != 0 {
+		t.Errorf("expected no hits, got %d", hits)
+	}
+
+	// A response that cannot be stored replaces the outdated stored one
+	atomic.StoreInt32(&hits, 0)
+	for i, want := range []string{"1", "2", "3"} {
+		if rd := ExecuteJsonApi("GET", srv.URL+"/changed", nil, false, nil, 5, nil, Cache(store)); string(rd.Data) != want {
+			t.Errorf("request %d: expected %s, got %s", i+1, want, rd.Data)
+		}
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2, 10)
+	c.Set("a", CacheEntry{Body: []byte("aaaa")})
+	c.Set("b", CacheEntry{Body: []byte("bbbb")})
+	c.Get("a")
+	c.Set("c", CacheEntry{Body: []byte("cc")})
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("expected a to be kept")
+	}
+	c.Set("d", CacheEntry{Body: []byte("dddddddd")})
+	if c.Len() != 1 {
+		t.Errorf("expected 1 entry within the size limit, got %d", c.Len())
+	}
+	c.Set("e", CacheEntry{Body: []byte("too large body")})
+	if _, ok := c.Get("e"); ok {
+		t.Error("expected a body larger than the cache not to be stored")
+	}
+}
+
+func TestCacheSeparation(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/cookie":
+			w.Header().Set("Cache-Control", "max-age=60")
+			ck, _ := r.Cookie("session")
+			fmt.Fprintf(w, `{"status":"OK","data":%q}`, ck.Value)
+			return
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		}
+		fmt.Fprintf(w, `{"status":"OK","data":%q}`, r.Header.Get("Accept-Language"))
+	}))
+	defer srv.Close()
+
+	read := func(path string, opts ...RequestOption) string {
+		t.Helper()
+		rd := ExecuteJsonApi("GET", srv.URL+path, nil, false, nil, 5, nil, opts...)
+		if !rd.OK() {
+			t.Fatalf("%s: unexpected result %s %s", path, rd.Status, rd.Data)
+		}
+		return string(rd.Data)
+	}
+
+	// Users of different cookies get their own responses from the shared store
+	for _, user := range []string{"ann", "bob", "ann"} {
+		if got := read("/cookie", Cache(nil), Header("Cookie", "session="+user)); got != `"`+user+`"` {
+			t.Errorf("expected the response of %s, got %s", user, got)
+		}
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("expected 2 hits, got %d", n)
+	}
+
+	// Responses vary on the request headers listed in Vary
+	store := NewLRUCache(10, 0)
+	atomic.StoreInt32(&hits, 0)
+	for _, lang := range []string{"en", "en", "fr"} {
+		if got := read("/vary", Cache(store), Header("Accept-Language", lang)); got != `"`+lang+`"` {
+			t.Errorf("expected the response of %s, got %s", lang, got)
+		}
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("expected 2 hits, got %d", n)
+	}
+
+	// Private responses are kept out of the shared store only
+	atomic.StoreInt32(&hits, 0)
+	for i := 0; i < 2; i++ {
+		read("/private", Cache(nil))
+		read("/private", Cache(store))
+	}
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Errorf("expected 3 hits, got %d", n)
+	}
+}
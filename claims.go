@@ -0,0 +1,352 @@
+package stdutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+)
+
+type (
+	// Claims are the claims of a token: the registered claims, the claims of
+	// CustomPayload and extra claims such as roles or scopes.
+	//
+	// Extra claims are encoded with the other claims, but cannot replace them.
+	Claims struct {
+		CustomPayload
+		Extra map[string]any `json:"-"` // Extra claims
+	}
+	// ClaimsBuilder builds the claims of a token
+	//
+	//	token, err := stdutil.NewClaims().
+	//		Subject("user-1").
+	//		Audience("billing").
+	//		ExpiresIn(time.Hour).
+	//		Set("roles", []string{"admin"}).
+	//		Sign(key)
+	ClaimsBuilder struct {
+		c Claims
+	}
+)
+
+// NewClaims starts building the claims of a token, issued now
+func NewClaims() *ClaimsBuilder {
+	b := &ClaimsBuilder{}
+	b.c.IssuedAt = jwt.NumericDate(time.Now())
+	return b
+}
+
+// Issuer sets the issuer (iss) claim
+func (b *ClaimsBuilder) Issuer(iss string) *ClaimsBuilder {
+	b.c.Issuer = iss
+	return b
+}
+
+// Subject sets the subject (sub) claim
+func (b *ClaimsBuilder) Subject(sub string) *ClaimsBuilder {
+	b.c.Subject = sub
+	return b
+}
+
+// Audience sets the audience (aud) claim
+func (b *ClaimsBuilder) Audience(aud ...string) *ClaimsBuilder {
+	b.c.Audience = jwt.Audience(aud)
+	return b
+}
+
+// ID sets the token id (jti) claim
+func (b *ClaimsBuilder) ID(jti string) *ClaimsBuilder {
+	b.c.JWTID = jti
+	return b
+}
+
+// ExpiresAt sets the expiration time (exp) claim
+func (b *ClaimsBuilder) ExpiresAt(t time.Time) *ClaimsBuilder {
+	b.c.ExpirationTime = jwt.NumericDate(t)
+	return b
+}
+
+// ExpiresIn sets the expiration time (exp) claim to a duration from now
+func (b *ClaimsBuilder) ExpiresIn(d time.Duration) *ClaimsBuilder {
+	return b.ExpiresAt(time.Now().Add(d))
+}
+
+// NotBefore sets the not before (nbf) claim
+func (b *ClaimsBuilder) NotBefore(t time.Time) *ClaimsBuilder {
+	b.c.NotBefore = jwt.NumericDate(t)
+	return b
+}
+
+// IssuedAt sets the issued at (iat) claim
+func (b *ClaimsBuilder) IssuedAt(t time.Time) *ClaimsBuilder {
+	b.c.IssuedAt = jwt.NumericDate(t)
+	return b
+}
+
+// UserName sets the user name (usr) claim
+func (b *ClaimsBuilder) UserName(usr string) *ClaimsBuilder {
+	b.c.UserName = usr
+	return b
+}
+
+// Domain sets the domain (dom) claim
+func (b *ClaimsBuilder) Domain(dom string) *ClaimsBuilder {
+	b.c.Domain = dom
+	return b
+}
+
+// ApplicationID sets the application id (app) claim
+func (b *ClaimsBuilder) ApplicationID(app string) *ClaimsBuilder {
+	b.c.ApplicationID = app
+	return b
+}
+
+// DeviceID sets the device id (dev) claim
+func (b *ClaimsBuilder) DeviceID(dev string) *ClaimsBuilder {
+	b.c.DeviceID = dev
+	return b
+}
+
+// TenantID sets the tenant id (tnt) claim
+func (b *ClaimsBuilder) TenantID(tnt string) *ClaimsBuilder {
+	b.c.TenantID = tnt
+	return b
+}
+
+// Set sets an extra claim
+func (b *ClaimsBuilder) Set(name string, value any) *ClaimsBuilder {
+	if b.c.Extra == nil {
+		b.c.Extra = make(map[string]any)
+	}
+	b.c.Extra[name] = value
+	return b
+}
+
+// Claims returns the built claims
+func (b *ClaimsBuilder) Claims() Claims {
+	c := b.c
+	if b.c.Extra != nil {
+		c.Extra = make(map[string]any, len(b.c.Extra))
+		for k, v := range b.c.Extra {
+			c.Extra[k] = v
+		}
+	}
+	return c
+}
+
+// Sign builds a JWT token of the claims signed with the algorithm and key of the signer
+func (b *ClaimsBuilder) Sign(signer JwtSigner) (string, error) {
+	return SignJwtWith(b.Claims(), signer)
+}
+
+// MarshalJSON encodes the claims with the extra claims
+func (c Claims) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(c.CustomPayload)
+	if err != nil || len(c.Extra) == 0 {
+		return b, err
+	}
+	m := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range c.Extra {
+		if _, ok := m[k]; ok || isCustomClaim(k) {
+			continue
+		}
+		if m[k], err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes the claims, keeping the claims other than those of CustomPayload as extra claims
+func (c *Claims) UnmarshalJSON(b []byte) error {
+	var pl CustomPayload
+	if err := json.Unmarshal(b, &pl); err != nil {
+		return err
+	}
+	m := make(map[string]any)
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	c.CustomPayload = pl
+	c.Extra = nil
+	for k, v := range m {
+		if isCustomClaim(k) {
+			continue
+		}
+		if c.Extra == nil {
+			c.Extra = make(map[string]any)
+		}
+		c.Extra[k] = v
+	}
+	return nil
+}
+
+// SignJwtWith builds a JWT token of typed claims signed with the algorithm and key of the signer.
+// The claims must be a struct embedding jwt.Payload, such as Claims or CustomPayload, and
+// can have custom claims such as roles or scopes:
+//
+//	type AppClaims struct {
+//		jwt.Payload
+//		Roles []string `json:"roles"`
+//	}
+//	token, err := stdutil.SignJwtWith(AppClaims{Roles: roles}, key)
+func SignJwtWith[T any](claims T, signer JwtSigner) (string, error) {
+	if _, ok := jwtPayloadOf(reflect.ValueOf(&claims).Elem()); !ok {
+		return "", fmt.Errorf("jwt: claims %T do not embed jwt.Payload", claims)
+	}
+	return signJwt(claims, signer)
+}
+
+// jwtPayloadOf returns the jwt.Payload embedded in an addressable struct value, at any depth
+func jwtPayloadOf(rv reflect.Value) (*jwt.Payload, bool) {
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, false
+	}
+	if pl, ok := rv.Addr().Interface().(*jwt.Payload); ok {
+		return pl, true
+	}
+	for i := 0; i < rv.NumField(); i++ {
+		if !rv.Type().Field(i).Anonymous {
+			continue
+		}
+		if pl, ok := jwtPayloadOf(rv.Field(i)); ok {
+			return pl, true
+		}
+	}
+	return nil, false
+}
+
+// jwtClaims converts a claims map to the payload of a token.
+// String claims must be strings, the audience can be a string or a list of strings,
+// and times can be any number of seconds since the epoch or a time.
+// Claims other than those of CustomPayload are kept as they are.
+func jwtClaims(clm map[string]interface{}) (map[string]any, error) {
+	pl := make(map[string]any, len(clm))
+	for k, v := range clm {
+		if v == nil {
+			continue
+		}
+		switch k {
+		case "iss", "sub", "jti", "usr", "dom", "app", "dev", "tnt":
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("jwt: claim %q must be a string, not %T", k, v)
+			}
+			if s != "" {
+				pl[k] = s
+			}
+		case "aud":
+			aud, err := toAudience(v)
+			if err != nil {
+				return nil, err
+			}
+			if len(aud) > 0 {
+				pl[k] = aud
+			}
+		case "exp", "nbf", "iat":
+			t, err := toNumericDate(v)
+			if err != nil {
+				return nil, fmt.Errorf("jwt: claim %q: %w", k, err)
+			}
+			if t != nil {
+				pl[k] = t
+			}
+		default:
+			pl[k] = v
+		}
+	}
+	return pl, nil
+}
+
+// toAudience converts a string or a list of strings to an audience
+func toAudience(v any) (jwt.Audience, error) {
+	switch a := v.(type) {
+	case string:
+		if a == "" {
+			return nil, nil
+		}
+		return jwt.Audience{a}, nil
+	case []string:
+		return jwt.Audience(a), nil
+	case jwt.Audience:
+		return a, nil
+	case []any:
+		aud := make(jwt.Audience, 0, len(a))
+		for _, e := range a {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("jwt: claim \"aud\" must be a list of strings, not %T", e)
+			}
+			aud = append(aud, s)
+		}
+		return aud, nil
+	}
+	return nil, fmt.Errorf("jwt: claim \"aud\" must be a string or a list of strings, not %T", v)
+}
+
+// toNumericDate converts a number of seconds since the epoch, or a time, to a numeric date.
+// A nil time pointer is an absent claim, converted to nil.
+func toNumericDate(v any) (*jwt.Time, error) {
+	var secs float64
+	switch t := v.(type) {
+	case time.Time:
+		return jwt.NumericDate(t), nil
+	case *time.Time:
+		if t == nil {
+			return nil, nil
+		}
+		return jwt.NumericDate(*t), nil
+	case jwt.Time:
+		return jwt.NumericDate(t.Time), nil
+	case *jwt.Time:
+		if t == nil {
+			return nil, nil
+		}
+		return jwt.NumericDate(t.Time), nil
+	case json.Number:
+		f, err := t.Float64()
+		if err != nil {
+			return nil, err
+		}
+		secs = f
+	case int, int8, int16, int32, int64:
+		return jwt.NumericDate(time.Unix(reflect.ValueOf(t).Int(), 0)), nil
+	case uint, uint8, uint16, uint32, uint64:
+		u := reflect.ValueOf(t).Uint()
+		if u > math.MaxInt64 {
+			return nil, fmt.Errorf("value %d out of range", u)
+		}
+		return jwt.NumericDate(time.Unix(int64(u), 0)), nil
+	case float32:
+		secs = float64(t)
+	case float64:
+		secs = t
+	default:
+		return nil, fmt.Errorf("must be a number or a time, not %T", v)
+	}
+	if math.IsNaN(secs) || math.IsInf(secs, 0) || math.Abs(secs) > math.MaxInt64 {
+		return nil, fmt.Errorf("value %v out of range", secs)
+	}
+	return jwt.NumericDate(time.Unix(int64(secs), 0)), nil
+}
+
+// isCustomClaim checks if a claim is one of the claims of CustomPayload
+func isCustomClaim(name string) bool {
+	switch name {
+	case "iss", "sub", "aud", "exp", "nbf", "iat", "jti", "usr", "dom", "app", "dev", "tnt":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package stdutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+)
+
+func TestSignJwtCoercion(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	claims := map[string]interface{}{
+		"usr":   "ann",
+		"aud":   []interface{}{"billing", "admin"},
+		"exp":   float64(exp),
+		"iat":   int32(exp - 3600),
+		"roles": []string{"admin"},
+	}
+	token, err := SignJwt(&claims, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Claims
+	if _, err = jwt.Verify([]byte(token), jwt.NewHS256([]byte("secret")), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.UserName != "ann" || c.ExpirationTime.Unix() != exp || len(c.Audience) != 2 {
+		t.Errorf("unexpected claims %+v", c)
+	}
+	if roles, _ := c.Extra["roles"].([]any); len(roles) != 1 || roles[0] != "admin" {
+		t.Errorf("expected the roles claim, got %v", c.Extra)
+	}
+
+	for _, bad := range []map[string]interface{}{
+		{"usr": 1},
+		{"exp": "tomorrow"},
+		{"aud": []interface{}{1}},
+	} {
+		if _, err = SignJwt(&bad, "secret"); err == nil {
+			t.Errorf("%v: expected an error", bad)
+		}
+	}
+	for _, absent := range []map[string]interface{}{
+		{"exp": (*time.Time)(nil)},
+		{"nbf": (*jwt.Time)(nil)},
+	} {
+		if token, err = SignJwt(&absent, "secret"); err != nil {
+			t.Errorf("%v: %v", absent, err)
+			continue
+		}
+		var ac Claims
+		if _, err = jwt.Verify([]byte(token), jwt.NewHS256([]byte("secret")), &ac); err != nil || ac.ExpirationTime != nil || ac.NotBefore != nil {
+			t.Errorf("%v: expected no time claims, got %+v, %v", absent, ac, err)
+		}
+	}
+	if _, err = SignJwt(&claims, ""); err == nil {
+		t.Error("expected an error without a secret key")
+	}
+}
+
+func TestSignJwtWith(t *testing.T) {
+	type appClaims struct {
+		jwt.Payload
+		Roles []string `json:"roles"`
+	}
+	key := HmacKey("secret")
+	token, err := SignJwtWith(appClaims{
+		Payload: jwt.Payload{Subject: "user-1", ExpirationTime: jwt.NumericDate(time.Now().Add(time.Hour))},
+		Roles:   []string{"admin", "audit"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ac appClaims
+	if _, err = jwt.Verify([]byte(token), jwt.NewHS256([]byte("secret")), &ac); err != nil {
+		t.Fatal(err)
+	}
+	if ac.Subject != "user-1" || len(ac.Roles) != 2 {
+		t.Errorf("unexpected claims %+v", ac)
+	}
+	if _, err = SignJwtWith(struct{ Roles []string }{}, key); err == nil {
+		t.Error("expected an error for claims without jwt.Payload")
+	}
+
+	token, err = NewClaims().
+		Subject("user-2").
+		UserName("bob").
+		Audience("billing").
+		ExpiresIn(time.Hour).
+		Set("scope", "read write").
+		Set("usr", "ignored").
+		Sign(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Claims
+	if _, err = jwt.Verify([]byte(token), jwt.NewHS256([]byte("secret")), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Subject != "user-2" || c.UserName != "bob" || c.IssuedAt == nil || c.Extra["scope"] != "read write" {
+		t.Errorf("unexpected claims %+v", c)
+	}
+}
+
+func TestParseJwtInto(t *testing.T) {
+	type appClaims struct {
+		jwt.Payload
+		Roles  []string `json:"roles"`
+		Scopes string   `json:"scope"`
+	}
+	key := HmacKey("secret")
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	token, err := NewClaims().
+		Issuer("auth").
+		Subject("user-1").
+		ID("t1").
+		UserName("ann").
+		ExpiresAt(exp).
+		Set("roles", []string{"admin"}).
+		Set("scope", "read").
+		Sign(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := ParseJwtUsing(token, key, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Issuer != "auth" || info.Subject != "user-1" || info.JWTID != "t1" || info.UserName != "ann" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if !info.ExpirationTime.Equal(exp) || info.IssuedAt.IsZero() || !info.NotBefore.IsZero() {
+		t.Errorf("unexpected times %+v", info)
+	}
+	if info.Claims["scope"] != "read" || info.Claims["sub"] != "user-1" {
+		t.Errorf("unexpected claims %v", info.Claims)
+	}
+
+	var ac appClaims
+	if info, err = ParseJwtInto(token, key, true, &ac); err != nil {
+		t.Fatal(err)
+	}
+	if ac.Subject != "user-1" || len(ac.Roles) != 1 || ac.Roles[0] != "admin" || ac.Scopes != "read" || info.UserName != "ann" {
+		t.Errorf("unexpected claims %+v, info %+v", ac, info)
+	}
+
+	var m map[string]any
+	if _, err = ParseJwtInto(token, HmacKey("other"), true, &m); err == nil || m != nil {
+		t.Errorf("expected an error for a wrong key, got %v with %v", err, m)
+	}
+}
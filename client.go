@@ -0,0 +1,203 @@
+package stdutil
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ApiClient is a client of an API service bound to a base URL.
+// It holds the request options applied to every call, such as default
+// headers, authentication, time out and transport.
+//
+// Go does not allow generic methods, so calls are made through the
+// Client<REST verb> functions:
+//
+//	cli, _ := stdutil.NewApiClient("https://api.example.com/v1", stdutil.BearerToken(token))
+//	res := stdutil.ClientRead[[]User](ctx, cli, "users", query)
+type ApiClient struct {
+	baseURL *url.URL
+	opts    []RequestOption
+}
+
+// NewApiClient creates an API client bound to a base URL.
+//
+// The options are applied before the options of every call. Use the HeaderValues
+// and Header options for default headers, so that they are merged with the headers of a call.
+func NewApiClient(baseURL string, opts ...RequestOption) (*ApiClient, error) {
+	bu, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if bu.Scheme == "" || bu.Host == "" {
+		return nil, fmt.Errorf("api client: base url %q must be absolute", baseURL)
+	}
+	rp := RequestParam{}
+	if err = rp.apply(opts); err != nil {
+		return nil, err
+	}
+	return &ApiClient{
+		baseURL: bu,
+		opts:    append([]RequestOption(nil), opts...),
+	}, nil
+}
+
+// BaseURL returns the base URL of the client
+func (c *ApiClient) BaseURL() string {
+	return c.baseURL.String()
+}
+
+// URL joins a path to the base URL of the client and encodes the query.
+// The path can have its own query string, which is merged with the query.
+// An absolute URL, or a URL with a host, is used as is if it is on the scheme and host of the base URL,
+// so that the default credentials of the client are not sent to other hosts.
+func (c *ApiClient) URL(path string, query NameValues) (string, error) {
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+	u := ref
+	if ref.IsAbs() || ref.Host != "" {
+		u = c.baseURL.ResolveReference(ref)
+		if !strings.EqualFold(u.Scheme, c.baseURL.Scheme) || !strings.EqualFold(u.Host, c.baseURL.Host) {
+			return "", fmt.Errorf("api client: url %q is not on the host of the base url", path)
+		}
+	} else {
+		u = c.baseURL.JoinPath(ref.EscapedPath())
+		u.RawQuery = ref.RawQuery
+		u.Fragment = ref.Fragment
+	}
+	if len(query.Pair) == 0 {
+		return u.String(), nil
+	}
+	qv := u.Query()
+	for k, v := range EncodeQuery(query) {
+		qv[k] = append(qv[k], v...)
+	}
+	u.RawQuery = qv.Encode()
+	return u.String(), nil
+}
+
+// Options returns the options of the client followed by the options of a call
+func (c *ApiClient) Options(opts ...RequestOption) []RequestOption {
+	all := make([]RequestOption, 0, len(c.opts)+len(opts))
+	all = append(all, c.opts...)
+	return append(all, opts...)
+}
+
+// EncodeQuery converts name values to URL query values.
+// Slices are encoded as repeated values, and times are formatted in RFC 3339.
+func EncodeQuery(nv NameValues) url.Values {
+	qv := make(url.Values, len(nv.Pair))
+	str := func(v any) string {
+		switch t := v.(type) {
+		case time.Time:
+			return t.Format(time.RFC3339)
+		case *time.Time:
+			if t == nil {
+				return ""
+			}
+			return t.Format(time.RFC3339)
+		case fmt.Stringer:
+			return t.String()
+		}
+		return AnyToString(v)
+	}
+	for k, v := range nv.Pair {
+		if v == nil {
+			continue
+		}
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < rv.Len(); i++ {
+				qv.Add(k, str(rv.Index(i).Interface()))
+			}
+			continue
+		}
+		qv.Add(k, str(v))
+	}
+	return qv
+}
+
+// BearerToken sets the Authorization header to a bearer token as an option
+//
+// This is used with <REST verb>Api functions
+func BearerToken(token string) RequestOption {
+	return Header("Authorization", "Bearer "+token)
+}
+
+// BasicAuth sets the Authorization header to basic credentials as an option
+//
+// This is used with <REST verb>Api functions
+func BasicAuth(userName, password string) RequestOption {
+	return Header("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(userName+":"+password)))
+}
+
+// ClientCreate posts data on a path of an API client and converts the returned data into a resulting type
+func ClientCreate[T any, U any](ctx context.Context, c *ApiClient, path string, pl U, opts ...RequestOption) ResultAny[T] {
+	u, err := clientURL(c, path, NameValues{})
+	if err != nil {
+		return clientError[T](err)
+	}
+	return CreateApiCtx[T](ctx, u, pl, c.Options(opts...)...)
+}
+
+// ClientRead retrieves data on a path of an API client and converts the returned data into a resulting type
+func ClientRead[T any](ctx context.Context, c *ApiClient, path string, query NameValues, opts ...RequestOption) ResultAny[T] {
+	u, err := clientURL(c, path, query)
+	if err != nil {
+		return clientError[T](err)
+	}
+	return ReadApiCtx[T](ctx, u, c.Options(opts...)...)
+}
+
+// ClientUpdate updates data on a path of an API client and converts the returned data into a resulting type
+func ClientUpdate[T any, U any](ctx context.Context, c *ApiClient, path string, pl U, opts ...RequestOption) ResultAny[T] {
+	u, err := clientURL(c, path, NameValues{})
+	if err != nil {
+		return clientError[T](err)
+	}
+	return UpdateApiCtx[T](ctx, u, pl, c.Options(opts...)...)
+}
+
+// ClientDelete deletes data on a path of an API client and converts the returned data into a resulting type
+func ClientDelete[T any](ctx context.Context, c *ApiClient, path string, query NameValues, opts ...RequestOption) ResultAny[T] {
+	u, err := clientURL(c, path, query)
+	if err != nil {
+		return clientError[T](err)
+	}
+	return DeleteApiCtx[T](ctx, u, c.Options(opts...)...)
+}
+
+// ClientPatch patches data on a path of an API client and converts the returned data into a resulting type
+func ClientPatch[T any, U any](ctx context.Context, c *ApiClient, path string, pl U, opts ...RequestOption) ResultAny[T] {
+	u, err := clientURL(c, path, NameValues{})
+	if err != nil {
+		return clientError[T](err)
+	}
+	return PatchApiCtx[T](ctx, u, pl, c.Options(opts...)...)
+}
+
+// clientURL builds the URL of a call, failing if the client is not set
+func clientURL(c *ApiClient, path string, query NameValues) (string, error) {
+	if c == nil {
+		return "", errors.New(`api client not set`)
+	}
+	return c.URL(path, query)
+}
+
+// clientError returns an exception result of an error
+func clientError[T any](err error) ResultAny[T] {
+	return ResultAny[T]{
+		Result: InitResult(
+			NameValue[string]{
+				Name:  "message",
+				Value: err.Error(),
+			},
+		),
+	}
+}
@@ -0,0 +1,86 @@
+package stdutil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestApiClient(t *testing.T) {
+	type user struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Tenant") != "acme" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			json.NewEncoder(w).Encode(map[string]any{
+				"status": "OK",
+				"data":   []user{{ID: 1, Name: r.URL.Path + "?" + r.URL.RawQuery}},
+			})
+		case "POST":
+			var u user
+			json.NewDecoder(r.Body).Decode(&u)
+			u.ID = 2
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{"status": "OK", "data": u})
+		}
+	}))
+	defer srv.Close()
+
+	cli, err := NewApiClient(srv.URL+"/api/v1",
+		BearerToken("secret"),
+		HeaderValues(map[string][]string{"X-Tenant": {"acme"}}),
+		TimeOut(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	res := ClientRead[[]user](ctx, cli, "users/?active=true", NameValues{
+		Pair: map[string]any{"ids": []int{1, 2}},
+	}, Header("X-Call", "read"))
+	if !res.OK() || len(res.Data) != 1 {
+		t.Fatalf("unexpected result %s %v", res.Status, res.Messages)
+	}
+	if want := "/api/v1/users/?active=true&ids=1&ids=2"; res.Data[0].Name != want {
+		t.Errorf("expected %s, got %s", want, res.Data[0].Name)
+	}
+
+	cres := ClientCreate[user](ctx, cli, "users", user{Name: "new"})
+	if !cres.OK() || cres.Data.ID != 2 || cres.Data.Name != "new" {
+		t.Errorf("unexpected result %s %v %+v", cres.Status, cres.Messages, cres.Data)
+	}
+}
+
+func TestApiClientURL(t *testing.T) {
+	if _, err := NewApiClient("/relative"); err == nil {
+		t.Errorf("expected an error for a relative base url")
+	}
+	cli, _ := NewApiClient("https://example.com/base/")
+	at := time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)
+	for path, want := range map[string]string{
+		"items":                     "https://example.com/base/items?from=2024-05-16T00%3A00%3A00Z",
+		"/items/":                   "https://example.com/base/items/?from=2024-05-16T00%3A00%3A00Z",
+		"items/a%2Fb":               "https://example.com/base/items/a%2Fb?from=2024-05-16T00%3A00%3A00Z",
+		"https://example.com/x?y=1": "https://example.com/x?from=2024-05-16T00%3A00%3A00Z&y=1",
+	} {
+		got, err := cli.URL(path, NameValues{Pair: map[string]any{"from": at}})
+		if err != nil || got != want {
+			t.Errorf("%s: expected %s, got %s (%v)", path, want, got, err)
+		}
+	}
+
+	// Absolute URLs on other hosts would get the default credentials of the client
+	for _, path := range []string{"https://other.com/x", "http://example.com/x", "//other.com/x"} {
+		if got, err := cli.URL(path, NameValues{}); err == nil {
+			t.Errorf("%s: expected an error, got %s", path, got)
+		}
+	}
+}
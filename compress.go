@@ -0,0 +1,174 @@
+package stdutil
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// ContentDecoder returns a reader that decodes a response body of a content encoding
+type ContentDecoder func(r io.Reader) (io.ReadCloser, error)
+
+// defaultCompressMinSize is the payload size below which request bodies are not compressed
+const defaultCompressMinSize int = 1024
+
+var (
+	decoders = map[string]ContentDecoder{
+		"br":      brotliDecoder,
+		"gzip":    gzipDecoder,
+		"deflate": deflateDecoder,
+	}
+	decMu sync.RWMutex
+)
+
+// RegisterContentDecoder registers a decoder for a content encoding of responses.
+// Registered encodings are advertised in the Accept-Encoding header of compressed requests.
+//
+// Decoders for br (brotli), gzip and deflate are built in. A registered decoder replaces
+// the built-in one, and a nil decoder removes the encoding.
+func RegisterContentDecoder(encoding string, dec ContentDecoder) {
+	decMu.Lock()
+	defer decMu.Unlock()
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if dec == nil {
+		delete(decoders, encoding)
+		return
+	}
+	decoders[encoding] = dec
+}
+
+// Compression sets the gzip compression level of request bodies and the payload size in bytes
+// below which compression is skipped, as an option. Compression happens only if the request
+// is set as compressed. A level of 0 uses the default compression level, and a negative
+// minimum size uses the default of 1 KB.
+//
+// This is used with <REST verb>Api functions
+func Compression(level int, minSize int) RequestOption {
+	return func(rp *RequestParam) error {
+		if level != 0 && (level < gzip.HuffmanOnly || level > gzip.BestCompression) {
+			return fmt.Errorf("compression: invalid level %d", level)
+		}
+		rp.CompressLevel = level
+		rp.CompressMinSize = &minSize
+		return nil
+	}
+}
+
+// acceptEncoding returns the value of the Accept-Encoding header from the registered decoders
+func acceptEncoding() string {
+	decMu.RLock()
+	defer decMu.RUnlock()
+	encs := make([]string, 0, len(decoders))
+	for e := range decoders {
+		encs = append(encs, e)
+	}
+	sort.Strings(encs)
+	return strings.Join(encs, ", ")
+}
+
+// contentDecoder returns the decoder of a content encoding
+func contentDecoder(encoding string) (ContentDecoder, bool) {
+	decMu.RLock()
+	defer decMu.RUnlock()
+	dec, ok := decoders[encoding]
+	return dec, ok
+}
+
+// compressesBody checks if the body of a request is compressed
+func compressesBody(method string, body io.Reader, rp *RequestParam) bool {
+	if body == nil || !rp.Compressed {
+		return false
+	}
+	switch strings.ToUpper(method) {
+	case "POST", "PUT", "PATCH":
+		return true
+	}
+	return false
+}
+
+// streamsCompressed checks if the body of a request is compressed as it is read.
+// Such a body is read by a goroutine that may still run when the request is done.
+func streamsCompressed(method string, body io.Reader, rp *RequestParam) bool {
+	_, sized := body.(interface{ Len() int })
+	return compressesBody(method, body, rp) && !sized
+}
+
+// compressBody gzips the request body if it is large enough. Bodies with a known
+// size are compressed in memory, while other bodies are compressed as they are read.
+func compressBody(body io.Reader, rp *RequestParam) (io.Reader, bool, error) {
+	if body == nil {
+		return nil, false, nil
+	}
+	minSize := defaultCompressMinSize
+	if rp.CompressMinSize != nil && *rp.CompressMinSize >= 0 {
+		minSize = *rp.CompressMinSize
+	}
+	level := rp.CompressLevel
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if sz, ok := body.(interface{ Len() int }); ok {
+		if sz.Len() == 0 || sz.Len() < minSize {
+			return body, false, nil
+		}
+		buf := bytes.Buffer{}
+		gzw, _ := gzip.NewWriterLevel(&buf, level)
+		if _, err := io.Copy(gzw, body); err != nil {
+			return nil, false, err
+		}
+		if err := gzw.Close(); err != nil {
+			return nil, false, err
+		}
+		return bytes.NewReader(buf.Bytes()), true, nil
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		gzw, _ := gzip.NewWriterLevel(pw, level)
+		_, err := io.Copy(gzw, body)
+		if cerr := gzw.Close(); err == nil {
+			err = cerr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, true, nil
+}
+
+// closeBody closes a body that will not be sent, stopping the encoding of a streamed compressed body
+func closeBody(body io.Reader, err error) {
+	if pr, ok := body.(*io.PipeReader); ok {
+		pr.CloseWithError(err)
+	}
+}
+
+// gzipDecoder decodes gzip content
+func gzipDecoder(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// brotliDecoder decodes brotli content
+func brotliDecoder(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(brotli.NewReader(r)), nil
+}
+
+// deflateDecoder decodes deflate content. Servers send either zlib-wrapped
+// (as the specification says) or raw deflate data, so both are accepted.
+func deflateDecoder(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	hdr, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if hdr[0]&0x0f == 8 && (uint16(hdr[0])<<8|uint16(hdr[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
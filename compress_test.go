@@ -0,0 +1,93 @@
+package stdutil
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestCompressedRequestBody(t *testing.T) {
+	var (
+		gotEncoding string
+		gotBody     string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = r.Header.Get("Content-Encoding")
+		var rd io.Reader = r.Body
+		if gotEncoding == "gzip" {
+			gzr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			rd = gzr
+		}
+		b, _ := io.ReadAll(rd)
+		gotBody = string(b)
+		w.Write([]byte(`{"status":"OK","messages":[],"data":null}`))
+	}))
+	defer srv.Close()
+
+	large := map[string]string{"text": strings.Repeat("compress me ", 200)}
+	res := CreateApi[any](srv.URL, large, Compressed(true), Compression(gzip.BestCompression, 0))
+	if !res.OK() || gotEncoding != "gzip" || !strings.Contains(gotBody, "compress me") {
+		t.Errorf("expected a gzipped body, got %q %s %v", gotEncoding, res.Status, res.Messages)
+	}
+
+	// Payloads below the threshold are sent as is
+	res = CreateApi[any](srv.URL, map[string]string{"text": "tiny"}, Compressed(true))
+	if !res.OK() || gotEncoding != "" || gotBody != `{"text":"tiny"}` {
+		t.Errorf("expected a plain body, got %q %q", gotEncoding, gotBody)
+	}
+}
+
+func TestDecodeResponseBody(t *testing.T) {
+	payload := `{"status":"OK","messages":[],"data":"decoded"}`
+	encode := map[string]func(w io.Writer) io.WriteCloser{
+		"br":   func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) },
+		"gzip": func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser {
+			return zlib.NewWriter(w)
+		},
+		"rawdeflate": func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := strings.TrimPrefix(r.URL.Path, "/")
+		buf := bytes.Buffer{}
+		ew := encode[enc](&buf)
+		ew.Write([]byte(payload))
+		ew.Close()
+		w.Header().Set("Content-Encoding", strings.TrimPrefix(enc, "raw"))
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	for enc := range encode {
+		res := ReadApi[string](srv.URL+"/"+enc, Compressed(true))
+		if !res.OK() || res.Data != "decoded" {
+			t.Errorf("%s: unexpected result %s %v", enc, res.Status, res.Messages)
+		}
+	}
+
+	if ae := acceptEncoding(); ae != "br, deflate, gzip" {
+		t.Errorf("unexpected Accept-Encoding %q", ae)
+	}
+	RegisterContentDecoder("zstd", func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(r), nil
+	})
+	defer RegisterContentDecoder("zstd", nil)
+	if ae := acceptEncoding(); ae != "br, deflate, gzip, zstd" {
+		t.Errorf("unexpected Accept-Encoding %q", ae)
+	}
+}
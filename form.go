@@ -0,0 +1,141 @@
+package stdutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// FilePart is a file of a multipart/form-data body
+type FilePart struct {
+	FieldName   string    // Name of the form field
+	FileName    string    // Name of the file
+	Reader      io.Reader // Content of the file. It is read as the body is sent
+	ContentType string    // Content type of the file. Default: application/octet-stream
+}
+
+// NewFormBody encodes the fields as an application/x-www-form-urlencoded body.
+// It returns the body and its content type.
+// Slices are encoded as repeated values, and times are formatted in RFC 3339.
+func NewFormBody(fields NameValues) (io.Reader, string) {
+	return strings.NewReader(EncodeQuery(fields).Encode()), "application/x-www-form-urlencoded"
+}
+
+// NewMultipartBody encodes the fields and files as a multipart/form-data body.
+// It returns the body and its content type with the boundary.
+//
+// The body is written as it is read, so large files are streamed without being buffered.
+// The body must be read to its end or closed to release the files.
+func NewMultipartBody(fields NameValues, files ...FilePart) (io.ReadCloser, string) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeMultipart(mw, fields, files))
+	}()
+	return pr, mw.FormDataContentType()
+}
+
+// writeMultipart writes the fields, sorted by name, and the files of a multipart body
+func writeMultipart(mw *multipart.Writer, fields NameValues, files []FilePart) error {
+	qv := EncodeQuery(fields)
+	names := make([]string, 0, len(qv))
+	for k := range qv {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		for _, v := range qv[k] {
+			if err := mw.WriteField(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range files {
+		if f.Reader == nil {
+			return fmt.Errorf("multipart: file %q of field %q has no reader", f.FileName, f.FieldName)
+		}
+		ctype := f.ContentType
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition",
+			fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+				escapeQuotes(f.FieldName), escapeQuotes(f.FileName)))
+		h.Set("Content-Type", ctype)
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(w, f.Reader); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// PostForm posts the fields as an application/x-www-form-urlencoded body and returns a custom result
+//
+// Request options, such as Headers, can be added to alter the request.
+func PostForm(endPoint string, fields NameValues, opts ...RequestOption) ResultData {
+	return PostFormCtx(context.Background(), endPoint, fields, opts...)
+}
+
+// PostFormCtx posts the fields as an application/x-www-form-urlencoded body with a context and returns a custom result
+func PostFormCtx(ctx context.Context, endPoint string, fields NameValues, opts ...RequestOption) ResultData {
+	body, ctype := NewFormBody(fields)
+	return postBody(ctx, "postform", endPoint, body, ctype, opts)
+}
+
+// PostMultipart posts the fields and files as a multipart/form-data body and returns a custom result.
+// The files are streamed, so the request is not retried even if a retry policy is set.
+//
+// Request options, such as Headers, can be added to alter the request.
+func PostMultipart(endPoint string, fields NameValues, files []FilePart, opts ...RequestOption) ResultData {
+	return PostMultipartCtx(context.Background(), endPoint, fields, files, opts...)
+}
+
+// PostMultipartCtx posts the fields and files as a multipart/form-data body with a context and returns a custom result
+func PostMultipartCtx(ctx context.Context, endPoint string, fields NameValues, files []FilePart, opts ...RequestOption) ResultData {
+	body, ctype := NewMultipartBody(fields, files...)
+	defer body.Close()
+	return postBody(ctx, "postmultipart", endPoint, body, ctype, opts)
+}
+
+// postBody posts a body of a content type and returns a custom result of the operation
+func postBody(ctx context.Context, op string, endPoint string, body io.Reader, contentType string, opts []RequestOption) ResultData {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	rp := RequestParam{}
+	if err := rp.apply(opts); err != nil {
+		return resultData(newResult(op), nil, err, &rp)
+	}
+
+	// The content type carries the boundary of multipart bodies, so it cannot be overridden
+	hv := make(map[string][]string, len(rp.HeaderValues)+1)
+	for k, v := range rp.HeaderValues {
+		if !strings.EqualFold(k, "Content-Type") {
+			hv[k] = v
+		}
+	}
+	hv["Content-Type"] = []string{contentType}
+	rp.HeaderValues = hv
+
+	rc, err := executeApiStream(ctx, "POST", endPoint, body, &rp)
+	if err != nil {
+		return resultData(newResult(op), nil, err, &rp)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	return resultData(newResult(op), data, contextError(ctx, err), &rp)
+}
+
+// escapeQuotes escapes the quotes and backslashes of a multipart header parameter
+func escapeQuotes(s string) string {
+	return strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace(s)
+}
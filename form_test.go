@@ -0,0 +1,70 @@
+package stdutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostForm(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.ParseForm()
+		fmt.Fprintf(w, `{"status":"OK","data":%q}`, r.PostForm.Get("name")+"|"+strings.Join(r.PostForm["tags"], ","))
+	}))
+	defer srv.Close()
+	rd := PostForm(srv.URL, NameValues{
+		Pair: map[string]any{"name": "a b&c", "tags": []string{"x", "y"}},
+	}, Header("Content-Type", "application/json"))
+	if !rd.OK() || string(rd.Data) != `"a b&c|x,y"` {
+		t.Errorf("unexpected result %s %s %s", rd.Status, rd.Data, rd.MessagesToString())
+	}
+}
+
+func TestPostMultipart(t *testing.T) {
+	const size = 4 << 20
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fh := r.MultipartForm.File["upload"][0]
+		f, _ := fh.Open()
+		n, _ := io.Copy(io.Discard, f)
+		f.Close()
+		fmt.Fprintf(w, `{"status":"OK","data":%q}`,
+			fmt.Sprintf("%s|%s|%s|%d|%s", r.FormValue("title"), fh.Filename, fh.Header.Get("Content-Type"), n, r.FormValue("note")))
+	}))
+	defer srv.Close()
+	rd := PostMultipart(srv.URL, NameValues{
+		Pair: map[string]any{"title": "report"},
+	}, []FilePart{
+		{FieldName: "upload", FileName: `big "file".bin`, Reader: io.LimitReader(zeroReader{}, size)},
+		{FieldName: "note", FileName: "note.txt", Reader: bytes.NewReader([]byte("hi")), ContentType: "text/plain"},
+	})
+	want := fmt.Sprintf(`"report|big \"file\".bin|application/octet-stream|%d|"`, size)
+	if !rd.OK() || string(rd.Data) != want {
+		t.Errorf("unexpected result %s %s %s", rd.Status, rd.Data, rd.MessagesToString())
+	}
+
+	// A file without a reader fails the request
+	rd = PostMultipart(srv.URL, NameValues{}, []FilePart{{FieldName: "upload", FileName: "none"}})
+	if rd.OK() {
+		t.Error("expected a failure")
+	}
+}
+
+// zeroReader is an endless reader of zeros
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
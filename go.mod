@@ -5,6 +5,7 @@ go 1.22.0
 toolchain go1.23.2
 
 require (
+	github.com/andybalholm/brotli v1.2.0
 	github.com/gbrlsnchs/jwt/v3 v3.0.1
 	github.com/gorilla/mux v1.8.1
 )
@@ -0,0 +1,187 @@
+package stdutil
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+type (
+	// RoundTrip sends a request and returns its response
+	RoundTrip func(req *http.Request) (*http.Response, error)
+	// Interceptor wraps a RoundTrip to inspect or alter the outgoing request
+	// and the incoming response or error of API calls
+	Interceptor func(next RoundTrip) RoundTrip
+
+	// interceptTransport is a transport that runs the request through a chain of interceptors
+	interceptTransport struct {
+		rt RoundTrip
+	}
+	// requestIDKey is the context key of the request id
+	requestIDKey struct{}
+)
+
+var (
+	interceptors []Interceptor // interceptors registered for all requests
+	icMu         sync.RWMutex
+)
+
+// UseInterceptors registers interceptors that run on every API call.
+// Interceptors run in the order they are registered, before those set by the Intercept option.
+func UseInterceptors(ic ...Interceptor) {
+	icMu.Lock()
+	defer icMu.Unlock()
+	for _, i := range ic {
+		if i != nil {
+			interceptors = append(interceptors, i)
+		}
+	}
+}
+
+// ClearInterceptors removes all interceptors registered by UseInterceptors
+func ClearInterceptors() {
+	icMu.Lock()
+	defer icMu.Unlock()
+	interceptors = nil
+}
+
+// Intercept adds interceptors to the request as an option
+//
+// This is used with <REST verb>Api functions
+func Intercept(ic ...Interceptor) RequestOption {
+	return func(rp *RequestParam) error {
+		for _, i := range ic {
+			if i != nil {
+				rp.Interceptors = append(rp.Interceptors, i)
+			}
+		}
+		return nil
+	}
+}
+
+// ContextWithRequestID returns a context carrying the request id to propagate to API calls
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id carried by the context
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// RequestIDInterceptor sets the request id header of outgoing requests.
+//
+// The id is taken from the context of the request (see ContextWithRequestID).
+// If the context has none, the id of the header is kept, or a new id is generated if
+// the header is not yet set. The header defaults to "X-Request-ID" if empty.
+//
+// The id is also set in the context of the request sent, where LoggingInterceptor reads it.
+func RequestIDInterceptor(header string) Interceptor {
+	if header == "" {
+		header = "X-Request-ID"
+	}
+	return func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			id, ok := RequestIDFromContext(ctx)
+			if !ok {
+				if id = req.Header.Get(header); id == "" {
+					id = GenerateFull(24)
+				}
+				ctx = ContextWithRequestID(ctx, id)
+			}
+			req = req.Clone(ctx)
+			req.Header.Set(header, id)
+			return next(req)
+		}
+	}
+}
+
+// LoggingInterceptor logs every request with its status and duration using a structured logger.
+// If logger is nil, the default logger is used.
+//
+// The request id is the one set by RequestIDInterceptor, whatever its header, or else
+// the id of the context of the request or of the X-Request-ID header.
+func LoggingInterceptor(logger *slog.Logger) Interceptor {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			lg := logger
+			if lg == nil {
+				lg = slog.Default()
+			}
+			start := time.Now()
+			resp, err := next(req)
+			attrs := []any{
+				slog.String("method", req.Method),
+				slog.String("url", redactedURL(req.URL)),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if id := requestID(req, resp); id != "" {
+				attrs = append(attrs, slog.String("request_id", id))
+			}
+			if err != nil {
+				lg.ErrorContext(req.Context(), "api request failed", append(attrs, slog.String("error", err.Error()))...)
+				return resp, err
+			}
+			attrs = append(attrs, slog.Int("status", resp.StatusCode))
+			if resp.StatusCode >= 400 {
+				lg.WarnContext(req.Context(), "api request", attrs...)
+				return resp, err
+			}
+			lg.InfoContext(req.Context(), "api request", attrs...)
+			return resp, err
+		}
+	}
+}
+
+// requestID returns the request id of a logged request. The request sent, which the response
+// refers to, is looked up first, as the id may be set by the interceptors after the logging one.
+func requestID(req *http.Request, resp *http.Response) string {
+	if resp != nil && resp.Request != nil {
+		req = resp.Request
+	}
+	if id, ok := RequestIDFromContext(req.Context()); ok {
+		return id
+	}
+	return req.Header.Get("X-Request-ID")
+}
+
+// redactedURL returns a URL to be logged, with the password and the query values
+// redacted, as query strings often carry secrets such as access tokens or API keys
+func redactedURL(u *url.URL) string {
+	ru := *u
+	if ru.RawQuery != "" {
+		qv := ru.Query()
+		for k := range qv {
+			qv[k] = []string{"REDACTED"}
+		}
+		ru.RawQuery = qv.Encode()
+	}
+	return ru.Redacted()
+}
+
+// RoundTrip implements http.RoundTripper
+func (it *interceptTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return it.rt(req)
+}
+
+// chainInterceptors wraps the transport with the global interceptors and the interceptors of the request
+func chainInterceptors(rt http.RoundTripper, ic []Interceptor) http.RoundTripper {
+	icMu.RLock()
+	all := make([]Interceptor, 0, len(interceptors)+len(ic))
+	all = append(all, interceptors...)
+	icMu.RUnlock()
+	all = append(all, ic...)
+	if len(all) == 0 {
+		return rt
+	}
+	var next RoundTrip = rt.RoundTrip
+	for i := len(all) - 1; i >= 0; i-- {
+		next = all[i](next)
+	}
+	return &interceptTransport{rt: next}
+}
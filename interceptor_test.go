@@ -0,0 +1,91 @@
+package stdutil
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInterceptors(t *testing.T) {
+	var gotID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = r.Header.Get("X-Request-ID")
+		w.Write([]byte(`{"status":"OK","messages":[],"data":null}`))
+	}))
+	defer srv.Close()
+
+	order := []string{}
+	tag := func(name string) Interceptor {
+		return func(next RoundTrip) RoundTrip {
+			return func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				resp, err := next(req)
+				if err == nil {
+					order = append(order, name+":"+resp.Status)
+				}
+				return resp, err
+			}
+		}
+	}
+	UseInterceptors(tag("global"), RequestIDInterceptor(""))
+	defer ClearInterceptors()
+
+	ctx := ContextWithRequestID(context.Background(), "req-123")
+	res := ReadApiCtx[any](ctx, srv.URL, Intercept(tag("call")))
+	if !res.OK() {
+		t.Fatalf("unexpected result %s %v", res.Status, res.Messages)
+	}
+	if gotID != "req-123" {
+		t.Errorf("request id not propagated, got %q", gotID)
+	}
+	want := "global,call,call:200 OK,global:200 OK"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// A new id is generated when the context has none
+	ReadApi[any](srv.URL)
+	if len(gotID) != 24 {
+		t.Errorf("expected a generated request id, got %q", gotID)
+	}
+}
+
+func TestLoggingInterceptor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+
+	buf := bytes.Buffer{}
+	lg := slog.New(slog.NewTextHandler(&buf, nil))
+	ReadApi[any](srv.URL+"/brew?access_token=s3cr3t-token&api_key=s3cr3t-key", Intercept(LoggingInterceptor(lg)))
+	out := buf.String()
+	if !strings.Contains(out, "level=WARN") || !strings.Contains(out, "status=418") || !strings.Contains(out, "/brew") {
+		t.Errorf("unexpected log %s", out)
+	}
+	if strings.Contains(out, "s3cr3t") || !strings.Contains(out, "access_token=REDACTED") {
+		t.Errorf("expected the query values to be redacted, got %s", out)
+	}
+
+	// The request id is logged whatever the header of the request id interceptor and its order
+	for _, ic := range [][]Interceptor{
+		{LoggingInterceptor(lg), RequestIDInterceptor("X-Correlation-ID")},
+		{RequestIDInterceptor("X-Correlation-ID"), LoggingInterceptor(lg)},
+	} {
+		buf.Reset()
+		ctx := ContextWithRequestID(context.Background(), "req-456")
+		ReadApiCtx[any](ctx, srv.URL, Intercept(ic...))
+		if out := buf.String(); !strings.Contains(out, "request_id=req-456") {
+			t.Errorf("expected the request id to be logged, got %s", out)
+		}
+		buf.Reset()
+		ReadApi[any](srv.URL, Intercept(ic...))
+		if out := buf.String(); !strings.Contains(out, "request_id=") {
+			t.Errorf("expected the generated request id to be logged, got %s", out)
+		}
+	}
+}
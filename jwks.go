@@ -0,0 +1,511 @@
+package stdutil
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+)
+
+type (
+	// Jwk is a JSON Web Key (RFC 7517). Private members are only set in key sets used to sign.
+	Jwk struct {
+		Kty string `json:"kty"`           // Key type: RSA, EC, OKP or oct
+		Kid string `json:"kid,omitempty"` // Key id
+		Alg string `json:"alg,omitempty"` // Algorithm of the key
+		Use string `json:"use,omitempty"` // Use of the key, such as "sig"
+		N   string `json:"n,omitempty"`   // RSA modulus
+		E   string `json:"e,omitempty"`   // RSA public exponent
+		Crv string `json:"crv,omitempty"` // Curve of EC and OKP keys
+		X   string `json:"x,omitempty"`   // X coordinate of EC keys, or public key of OKP keys
+		Y   string `json:"y,omitempty"`   // Y coordinate of EC keys
+		D   string `json:"d,omitempty"`   // Private exponent or key
+		P   string `json:"p,omitempty"`   // First prime factor of RSA private keys
+		Q   string `json:"q,omitempty"`   // Second prime factor of RSA private keys
+		K   string `json:"k,omitempty"`   // Secret of oct keys
+	}
+	// Jwks is a JSON Web Key Set
+	Jwks struct {
+		Keys []Jwk `json:"keys"`
+	}
+	// KeySetOptions sets how a key set is refreshed from an HTTP endpoint
+	KeySetOptions struct {
+		RefreshInterval    time.Duration   // Time after which the keys are fetched again. Default: 1 hour
+		MinRefreshInterval time.Duration   // Minimum time between fetches triggered by unknown key ids. Default: 1 minute
+		RequestOptions     []RequestOption // Options of the requests fetching the keys
+	}
+	// KeySet is a set of keys selected by the key id (kid) of tokens, for key rotation.
+	// It implements JwtVerifier, picking the key of the kid of a token, and JwtSigner,
+	// signing with the current key.
+	//
+	// A key set loaded from an HTTP endpoint is fetched again in the background after the
+	// refresh interval, or right away when a token has an unknown key id. The keys in use
+	// are kept if a fetch fails.
+	KeySet struct {
+		keys        map[string]*JwtKey
+		current     string
+		url         string
+		opts        KeySetOptions
+		fetchedAt   time.Time // Time of the last successful fetch
+		attemptedAt time.Time // Time the last fetch ended, successful or not
+		mu          sync.RWMutex
+		fetchMu     sync.Mutex
+	}
+	// keySetVerifier verifies tokens with a key set, fetching unknown key ids within a context
+	keySetVerifier struct {
+		ks  *KeySet
+		ctx context.Context
+	}
+)
+
+// NewKeySet creates a key set of keys with distinct key ids.
+// The first key that can sign is the current key.
+func NewKeySet(keys ...*JwtKey) (*KeySet, error) {
+	ks := &KeySet{}
+	if err := ks.set(keys); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// LoadKeySetFile loads a key set from a JWKS file
+func LoadKeySetFile(path string) (*KeySet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseJwks(b)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(keys...)
+}
+
+// LoadKeySetURL loads a key set from a JWKS HTTP endpoint. The keys are cached and fetched again
+// after the refresh interval of the options, or when a token has an unknown key id.
+func LoadKeySetURL(url string, opts KeySetOptions) (*KeySet, error) {
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = time.Hour
+	}
+	if opts.MinRefreshInterval <= 0 {
+		opts.MinRefreshInterval = time.Minute
+	}
+	ks := &KeySet{
+		url:  url,
+		opts: opts,
+	}
+	if err := ks.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Refresh fetches the keys of a key set loaded from an HTTP endpoint
+func (ks *KeySet) Refresh(ctx context.Context) error {
+	if ks.url == "" {
+		return nil
+	}
+	ks.fetchMu.Lock()
+	defer ks.fetchMu.Unlock()
+	return ks.fetch(ctx)
+}
+
+// Key returns the key of a key id
+func (ks *KeySet) Key(kid string) (*JwtKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// KeyIDs returns the key ids of the key set
+func (ks *KeySet) KeyIDs() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	return kids
+}
+
+// Add adds a key to the key set, replacing the key of the same key id
+func (ks *KeySet) Add(k *JwtKey) error {
+	if k == nil || k.kid == "" {
+		return errors.New(`jwks: key id not set`)
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if ks.keys == nil {
+		ks.keys = make(map[string]*JwtKey)
+	}
+	ks.keys[k.kid] = k
+	if ks.current == "" && k.signer != nil {
+		ks.current = k.kid
+	}
+	return nil
+}
+
+// Remove removes the key of a key id from the key set
+func (ks *KeySet) Remove(kid string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	delete(ks.keys, kid)
+	if ks.current == kid {
+		ks.current = ""
+	}
+}
+
+// SetCurrent sets the key that signs tokens
+func (ks *KeySet) SetCurrent(kid string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k, ok := ks.keys[kid]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrJwtKeyID, kid)
+	}
+	if k.signer == nil {
+		return ErrJwtNoSigningKey
+	}
+	ks.current = kid
+	return nil
+}
+
+// SigningAlgorithm returns the algorithm and key id of the current key
+func (ks *KeySet) SigningAlgorithm() (jwt.Algorithm, string, error) {
+	ks.mu.RLock()
+	k, ok := ks.keys[ks.current]
+	ks.mu.RUnlock()
+	if !ok {
+		return nil, "", ErrJwtNoSigningKey
+	}
+	return k.SigningAlgorithm()
+}
+
+// VerifyingAlgorithm returns the algorithm of the key of the key id of a token.
+// A token without a key id is verified with the only key of the set, and a single
+// key without a key id verifies all tokens.
+//
+// Keys of unknown key ids are fetched without a deadline other than the time out of the
+// request options. Use WithContext to bind the fetch to the context of a request.
+func (ks *KeySet) VerifyingAlgorithm(hdr jwt.Header) (jwt.Algorithm, error) {
+	return ks.verifyingAlgorithm(context.Background(), hdr)
+}
+
+// WithContext returns a verifier of the key set that fetches the keys of unknown key ids within a context,
+// such as the context of the request whose token is verified
+func (ks *KeySet) WithContext(ctx context.Context) JwtVerifier {
+	return keySetVerifier{ks: ks, ctx: ctx}
+}
+
+// VerifyingAlgorithm returns the algorithm of the key of the key id of a token
+func (v keySetVerifier) VerifyingAlgorithm(hdr jwt.Header) (jwt.Algorithm, error) {
+	return v.ks.verifyingAlgorithm(v.ctx, hdr)
+}
+
+// verifyingAlgorithm returns the algorithm of the key of the key id of a token,
+// fetching the keys if the key id is unknown or the keys are stale
+func (ks *KeySet) verifyingAlgorithm(ctx context.Context, hdr jwt.Header) (jwt.Algorithm, error) {
+	if ks.url != "" {
+		unknown := func() bool {
+			ks.mu.RLock()
+			defer ks.mu.RUnlock()
+			_, known := ks.lookup(hdr.KeyID)
+			return !known && hdr.KeyID != "" && time.Since(ks.attemptedAt) >= ks.opts.MinRefreshInterval
+		}
+		switch {
+		case unknown():
+			// Concurrent tokens of the same unknown key id wait for a single fetch.
+			// The keys in use are kept if the fetch fails.
+			ks.fetchMu.Lock()
+			if unknown() {
+				ks.fetch(ctx)
+			}
+			ks.fetchMu.Unlock()
+		case ks.stale() && ks.fetchMu.TryLock():
+			go func() {
+				defer ks.fetchMu.Unlock()
+				if ks.stale() {
+					ks.fetch(context.Background())
+				}
+			}()
+		}
+	}
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	k, ok := ks.lookup(hdr.KeyID)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrJwtKeyID, hdr.KeyID)
+	}
+	return k.VerifyingAlgorithm(hdr)
+}
+
+// PublicJwks returns the public keys of the key set, to be published on a JWKS endpoint.
+// HS256 keys are secret and are left out.
+func (ks *KeySet) PublicJwks() Jwks {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	set := Jwks{Keys: make([]Jwk, 0, len(ks.keys))}
+	for _, k := range ks.keys {
+		if jk, ok := publicJwk(k); ok {
+			set.Keys = append(set.Keys, jk)
+		}
+	}
+	return set
+}
+
+// fetch fetches the keys from the HTTP endpoint. It must be called with fetchMu held.
+func (ks *KeySet) fetch(ctx context.Context) error {
+	defer func() {
+		ks.mu.Lock()
+		ks.attemptedAt = time.Now()
+		ks.mu.Unlock()
+	}()
+	b, err := ExecuteApiCtx(ctx, "GET", ks.url, nil, true, nil, 0, ks.opts.RequestOptions...)
+	if err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+	keys, err := parseJwks(b)
+	if err != nil {
+		return err
+	}
+	if err = ks.set(keys); err != nil {
+		return err
+	}
+	ks.mu.Lock()
+	ks.fetchedAt = time.Now()
+	ks.mu.Unlock()
+	return nil
+}
+
+// stale checks if the keys are due to be fetched again. Failed fetches are retried
+// no sooner than the minimum refresh interval.
+func (ks *KeySet) stale() bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return time.Since(ks.fetchedAt) >= ks.opts.RefreshInterval && time.Since(ks.attemptedAt) >= ks.opts.MinRefreshInterval
+}
+
+// set replaces the keys of the key set, keeping the current key if it is still there.
+// A key without a key id is only allowed if it is the only key.
+func (ks *KeySet) set(keys []*JwtKey) error {
+	m := make(map[string]*JwtKey, len(keys))
+	current := ""
+	for _, k := range keys {
+		if k == nil || (k.kid == "" && len(keys) > 1) {
+			return errors.New(`jwks: key id not set`)
+		}
+		if _, ok := m[k.kid]; ok {
+			return fmt.Errorf("jwks: duplicate key id %q", k.kid)
+		}
+		m[k.kid] = k
+		if current == "" && k.signer != nil {
+			current = k.kid
+		}
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if k, ok := m[ks.current]; ok && k.signer != nil {
+		current = ks.current
+	}
+	ks.keys = m
+	ks.current = current
+	return nil
+}
+
+// lookup returns the key of a key id. The only key of the set verifies tokens without
+// a key id, and a key without a key id verifies all tokens. It must be called with mu held.
+func (ks *KeySet) lookup(kid string) (*JwtKey, bool) {
+	if len(ks.keys) == 1 {
+		for _, k := range ks.keys {
+			if kid == "" || k.kid == "" {
+				return k, true
+			}
+		}
+	}
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// parseJwks parses the keys of a JWKS document. Keys not used for signatures, and keys that
+// cannot be used, such as keys of unsupported algorithms or curves, are skipped. A key without
+// a key id is only kept if it is the only key. It fails if no key is left.
+func parseJwks(b []byte) ([]*JwtKey, error) {
+	var set Jwks
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	var skipped error
+	keys := make([]*JwtKey, 0, len(set.Keys))
+	for _, jk := range set.Keys {
+		if jk.Use != "" && jk.Use != "sig" {
+			continue
+		}
+		k, err := jk.key()
+		if err != nil {
+			skipped = errors.Join(skipped, fmt.Errorf("key %q: %w", jk.Kid, err))
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) > 1 {
+		keys = slices.DeleteFunc(keys, func(k *JwtKey) bool { return k.kid == "" })
+	}
+	if len(keys) == 0 {
+		if skipped != nil {
+			return nil, fmt.Errorf("jwks: no usable keys: %w", skipped)
+		}
+		return nil, errors.New(`jwks: no usable keys`)
+	}
+	return keys, nil
+}
+
+// key converts the JSON Web Key to a key
+func (jk Jwk) key() (*JwtKey, error) {
+	var (
+		priv crypto.PrivateKey
+		pub  crypto.PublicKey
+		alg  string
+	)
+	switch jk.Kty {
+	case "RSA":
+		alg = JwtRS256
+		n, err := b64Int(jk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64Int(jk.E)
+		if err != nil {
+			return nil, err
+		}
+		pk := &rsa.PublicKey{N: n, E: int(e.Int64())}
+		pub = pk
+		if jk.D != "" {
+			d, err := b64Int(jk.D)
+			if err != nil {
+				return nil, err
+			}
+			p, err := b64Int(jk.P)
+			if err != nil {
+				return nil, err
+			}
+			q, err := b64Int(jk.Q)
+			if err != nil {
+				return nil, err
+			}
+			rk := &rsa.PrivateKey{PublicKey: *pk, D: d, Primes: []*big.Int{p, q}}
+			if err = rk.Validate(); err != nil {
+				return nil, err
+			}
+			rk.Precompute()
+			priv, pub = rk, nil
+		}
+	case "EC":
+		alg = JwtES256
+		if jk.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", jk.Crv)
+		}
+		x, err := b64Int(jk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64Int(jk.Y)
+		if err != nil {
+			return nil, err
+		}
+		pk := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if !pk.Curve.IsOnCurve(x, y) {
+			return nil, errors.New(`point is not on the curve`)
+		}
+		pub = pk
+		if jk.D != "" {
+			d, err := b64Int(jk.D)
+			if err != nil {
+				return nil, err
+			}
+			priv, pub = &ecdsa.PrivateKey{PublicKey: *pk, D: d}, nil
+		}
+	case "OKP":
+		alg = JwtEdDSA
+		if jk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", jk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New(`invalid Ed25519 public key`)
+		}
+		pub = ed25519.PublicKey(x)
+		if jk.D != "" {
+			d, err := base64.RawURLEncoding.DecodeString(jk.D)
+			if err != nil || len(d) != ed25519.SeedSize {
+				return nil, errors.New(`invalid Ed25519 private key`)
+			}
+			priv, pub = ed25519.NewKeyFromSeed(d), nil
+		}
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(jk.K)
+		if err != nil {
+			return nil, err
+		}
+		return newJwtKey(JwtHS256, jk.Kid, secret, nil, nil)
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jk.Kty)
+	}
+	if jk.Alg != "" && jk.Alg != alg {
+		return nil, fmt.Errorf("unsupported algorithm %s for key type %s", jk.Alg, jk.Kty)
+	}
+	return newJwtKey(alg, jk.Kid, nil, priv, pub)
+}
+
+// publicJwk converts the public key of a key to a JSON Web Key
+func publicJwk(k *JwtKey) (Jwk, bool) {
+	jk := Jwk{
+		Kid: k.kid,
+		Alg: k.name,
+		Use: "sig",
+	}
+	enc := base64.RawURLEncoding.EncodeToString
+	switch pk := k.pub.(type) {
+	case *rsa.PublicKey:
+		jk.Kty = "RSA"
+		jk.N = enc(pk.N.Bytes())
+		jk.E = enc(big.NewInt(int64(pk.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jk.Kty = "EC"
+		jk.Crv = "P-256"
+		jk.X = enc(pk.X.FillBytes(make([]byte, 32)))
+		jk.Y = enc(pk.Y.FillBytes(make([]byte, 32)))
+	case ed25519.PublicKey:
+		jk.Kty = "OKP"
+		jk.Crv = "Ed25519"
+		jk.X = enc(pk)
+	default:
+		return Jwk{}, false
+	}
+	return jk, true
+}
+
+// b64Int decodes a base64url encoded big-endian integer
+func b64Int(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New(`missing key parameter`)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,270 @@
+package stdutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeySet(t *testing.T) {
+	rk, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ek, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	epub, edk, _ := ed25519.GenerateKey(rand.Reader)
+	newKey := func(alg string, kid string, priv any, pub any) *JwtKey {
+		pp, _ := pemKeys(t, priv, pub)
+		k, err := NewJwtKey(JwtOptions{Algorithm: alg, PrivateKey: pp, KeyID: kid})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	k1 := newKey(JwtRS256, "k1", rk, &rk.PublicKey)
+	k2 := newKey(JwtES256, "k2", ek, &ek.PublicKey)
+	k3 := newKey(JwtEdDSA, "k3", edk, epub)
+
+	signer, err := NewKeySet(k1, k2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := map[string]interface{}{"usr": "ann"}
+	t1, err := SignJwtUsing(&claims, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = signer.SetCurrent("k2"); err != nil {
+		t.Fatal(err)
+	}
+	t2, _ := SignJwtUsing(&claims, signer)
+
+	// Local stand-in of a JWKS endpoint
+	var (
+		mu   sync.Mutex
+		hits int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+		json.NewEncoder(w).Encode(signer.PublicJwks())
+	}))
+	defer srv.Close()
+	verifier, err := LoadKeySetURL(srv.URL, KeySetOptions{MinRefreshInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{t1, t2} {
+		if ji, err := ParseJwtUsing(token, verifier, false); err != nil || ji.UserName != "ann" {
+			t.Errorf("unexpected token info %+v %v", ji, err)
+		}
+	}
+	if _, _, err = verifier.SigningAlgorithm(); !errors.Is(err, ErrJwtNoSigningKey) {
+		t.Errorf("expected ErrJwtNoSigningKey, got %v", err)
+	}
+
+	// A rotated key is fetched when a token has its key id
+	signer.Add(k3)
+	signer.SetCurrent("k3")
+	t3, _ := SignJwtUsing(&claims, signer)
+	time.Sleep(2 * time.Millisecond)
+	if _, err = ParseJwtUsing(t3, verifier, false); err != nil {
+		t.Errorf("expected the rotated key to be fetched, got %v", err)
+	}
+	if hits != 2 {
+		t.Errorf("expected 2 fetches, got %d", hits)
+	}
+
+	// Retired keys are rejected
+	signer.Remove("k1")
+	time.Sleep(2 * time.Millisecond)
+	verifier.Refresh(context.Background())
+	if _, err = ParseJwtUsing(t1, verifier, false); !errors.Is(err, ErrJwtKeyID) {
+		t.Errorf("expected ErrJwtKeyID, got %v", err)
+	}
+
+	// Key set file
+	b, _ := json.Marshal(signer.PublicJwks())
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(path, b, 0o600)
+	fks, err := LoadKeySetFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseJwtUsing(t2, fks, false); err != nil {
+		t.Error(err)
+	}
+	if len(fks.KeyIDs()) != 2 {
+		t.Errorf("expected 2 keys, got %v", fks.KeyIDs())
+	}
+}
+
+func TestJwkPrivateKeys(t *testing.T) {
+	// RFC 8037 Ed25519 private key example
+	b := []byte(`{"keys":[
+		{"kty":"OKP","crv":"Ed25519","kid":"ed","d":"nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+		{"kty":"oct","kid":"hs","k":"c2VjcmV0"},
+		{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"}
+	]}`)
+	keys, err := parseJwks(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, err := NewKeySet(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ks.KeyIDs()) != 2 {
+		t.Errorf("expected the encryption key to be skipped, got %v", ks.KeyIDs())
+	}
+	claims := map[string]interface{}{"usr": "ann"}
+	for _, kid := range []string{"ed", "hs"} {
+		ks.SetCurrent(kid)
+		token, err := SignJwtUsing(&claims, ks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = ParseJwtUsing(token, ks, false); err != nil {
+			t.Errorf("%s: %v", kid, err)
+		}
+	}
+}
+
+func TestJwksUnusableKeys(t *testing.T) {
+	epub, edk, _ := ed25519.GenerateKey(rand.Reader)
+	pp, _ := pemKeys(t, edk, epub)
+	key, _ := NewJwtKey(JwtOptions{Algorithm: JwtEdDSA, PrivateKey: pp})
+	ks, _ := NewKeySet(key)
+	jwk := ks.PublicJwks().Keys[0]
+	claims := map[string]interface{}{"usr": "ann"}
+	token, _ := SignJwtUsing(&claims, key)
+
+	// Keys of other algorithms and curves, and keys without a key id among others, are skipped
+	other := func(kid, alg, crv string) Jwk {
+		jk := jwk
+		jk.Kid, jk.Alg, jk.Crv = kid, alg, crv
+		return jk
+	}
+	keys, err := parseJwks(mustJSON(t, Jwks{Keys: []Jwk{
+		other("rs384", "RS384", "Ed25519"),
+		other("p384", "", "P-384"),
+		other("", "", "Ed25519"),
+		other("ed", "", "Ed25519"),
+	}}))
+	if err != nil || len(keys) != 1 || keys[0].KeyID() != "ed" {
+		t.Fatalf("expected only the ed key, got %v %v", keys, err)
+	}
+	if _, err = parseJwks(mustJSON(t, Jwks{Keys: []Jwk{other("rs384", "RS384", "Ed25519")}})); err == nil {
+		t.Error("expected an error without usable keys")
+	}
+
+	// A single key without a key id verifies all tokens
+	var (
+		mu   sync.Mutex
+		body = mustJSON(t, Jwks{Keys: []Jwk{jwk}})
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(body)
+	}))
+	defer srv.Close()
+	verifier, err := LoadKeySetURL(srv.URL, KeySetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kidKey, _ := NewJwtKey(JwtOptions{Algorithm: JwtEdDSA, PrivateKey: pp, KeyID: "any"})
+	kidToken, _ := SignJwtUsing(&claims, kidKey)
+	for _, tk := range []string{token, kidToken} {
+		if _, err = ParseJwtUsing(tk, verifier, false); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// A fetch without usable keys keeps the keys in use
+	mu.Lock()
+	body = []byte(`{"keys":[]}`)
+	mu.Unlock()
+	if err = verifier.Refresh(context.Background()); err == nil {
+		t.Error("expected an error without usable keys")
+	}
+	if _, err = ParseJwtUsing(token, verifier, false); err != nil {
+		t.Errorf("expected the keys to be kept, got %v", err)
+	}
+}
+
+// mustJSON encodes a value to JSON
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestKeySetConcurrentRefresh(t *testing.T) {
+	epub, edk, _ := ed25519.GenerateKey(rand.Reader)
+	pp, _ := pemKeys(t, edk, epub)
+	known, _ := NewJwtKey(JwtOptions{Algorithm: JwtEdDSA, PrivateKey: pp, KeyID: "known"})
+	unknown, _ := NewJwtKey(JwtOptions{Algorithm: JwtEdDSA, PrivateKey: pp, KeyID: "unknown"})
+	ks, _ := NewKeySet(known)
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		time.Sleep(20 * time.Millisecond)
+		json.NewEncoder(w).Encode(ks.PublicJwks())
+	}))
+	defer srv.Close()
+	verifier, err := LoadKeySetURL(srv.URL, KeySetOptions{MinRefreshInterval: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(110 * time.Millisecond)
+
+	// Concurrent tokens of an unknown key id wait for a single fetch
+	claims := map[string]interface{}{"usr": "ann"}
+	token, _ := SignJwtUsing(&claims, unknown)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ParseJwtUsing(token, verifier, false); !errors.Is(err, ErrJwtKeyID) {
+				t.Errorf("expected ErrJwtKeyID, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("expected 2 fetches, got %d", n)
+	}
+
+	// The fetch of an unknown key id is bound to the context of the verifier
+	time.Sleep(110 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = ParseJwtUsing(token, verifier.WithContext(ctx), false); !errors.Is(err, ErrJwtKeyID) {
+		t.Errorf("expected ErrJwtKeyID, got %v", err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("expected no fetch with a canceled context, got %d fetches", n)
+	}
+
+	// Tokens of known key ids are verified without a fetch
+	knownToken, _ := SignJwtUsing(&claims, known)
+	if _, err = ParseJwtUsing(knownToken, verifier.WithContext(ctx), false); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,403 @@
+package stdutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/gbrlsnchs/jwt/v3/jwtutil"
+)
+
+// JWT algorithms
+const (
+	JwtHS256 string = "HS256" // HMAC using SHA-256 with a shared secret
+	JwtRS256 string = "RS256" // RSASSA-PKCS1-v1_5 using SHA-256
+	JwtES256 string = "ES256" // ECDSA using P-256 and SHA-256
+	JwtEdDSA string = "EdDSA" // EdDSA using Ed25519
+)
+
+// Errors
+var (
+	ErrJwtAlgorithm    = errors.New(`jwt: unexpected algorithm`)        // The algorithm of the token is not the one of the key
+	ErrJwtKeyID        = errors.New(`jwt: unexpected key id`)           // The key id of the token is not the one of the key
+	ErrJwtNoSigningKey = errors.New(`jwt: no private key to sign with`) // The key has no private key or secret
+)
+
+type (
+	// JwtSigner provides the algorithm and the key id to sign tokens with
+	JwtSigner interface {
+		SigningAlgorithm() (alg jwt.Algorithm, kid string, err error)
+	}
+	// JwtVerifier provides the algorithm to verify a token with, from the header of the token.
+	// Services that only verify tokens need a verifier holding public keys only.
+	JwtVerifier interface {
+		VerifyingAlgorithm(hdr jwt.Header) (jwt.Algorithm, error)
+	}
+	// JwtOptions selects the algorithm and the keys of a JwtKey
+	JwtOptions struct {
+		Algorithm  string // JwtHS256, JwtRS256, JwtES256 or JwtEdDSA. Default: JwtHS256
+		SecretKey  string // Shared secret of HS256
+		PrivateKey []byte // PEM encoded private key to sign with. Its public key verifies if PublicKey is not set
+		PublicKey  []byte // PEM encoded public key or certificate to verify with
+		KeyID      string // Key id set in the header of signed tokens, and expected in the header of verified tokens
+	}
+	// JwtKey is a key to sign and verify tokens with a single algorithm.
+	// It implements JwtSigner and JwtVerifier.
+	JwtKey struct {
+		name   string
+		kid    string
+		signer jwt.Algorithm // nil if the key can only verify
+		verify jwt.Algorithm
+		pub    crypto.PublicKey // nil for HS256
+	}
+)
+
+// NewJwtKey creates a key to sign and verify tokens from the options.
+// Asymmetric keys need a private key to sign, and either key to verify.
+func NewJwtKey(opts JwtOptions) (*JwtKey, error) {
+	name := opts.Algorithm
+	if name == "" {
+		name = JwtHS256
+	}
+	var (
+		priv crypto.PrivateKey
+		pub  crypto.PublicKey
+		err  error
+	)
+	if name != JwtHS256 {
+		if len(opts.PrivateKey) == 0 && len(opts.PublicKey) == 0 {
+			return nil, fmt.Errorf("jwt: %s needs a private or a public key", name)
+		}
+		if len(opts.PrivateKey) > 0 {
+			if priv, err = parsePrivateKeyPEM(opts.PrivateKey); err != nil {
+				return nil, err
+			}
+		}
+		if len(opts.PublicKey) > 0 {
+			if pub, err = parsePublicKeyPEM(opts.PublicKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return newJwtKey(name, opts.KeyID, []byte(opts.SecretKey), priv, pub)
+}
+
+// newJwtKey creates a key of an algorithm from a secret, or from a private or public key
+func newJwtKey(name string, kid string, secret []byte, priv crypto.PrivateKey, pub crypto.PublicKey) (*JwtKey, error) {
+	k := &JwtKey{
+		name: name,
+		kid:  kid,
+	}
+	switch k.name {
+	case JwtHS256:
+		if len(secret) == 0 {
+			return nil, fmt.Errorf(`secret key not set`)
+		}
+		k.signer = jwt.NewHS256(secret)
+		k.verify = k.signer
+	case JwtRS256:
+		var pk *rsa.PublicKey
+		if priv != nil {
+			rk, ok := priv.(*rsa.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("jwt: %s needs an RSA private key", k.name)
+			}
+			k.signer = jwt.NewRS256(jwt.RSAPrivateKey(rk))
+			pk = &rk.PublicKey
+		}
+		if pub != nil {
+			if pk, _ = pub.(*rsa.PublicKey); pk == nil {
+				return nil, fmt.Errorf("jwt: %s needs an RSA public key", k.name)
+			}
+		}
+		if pk == nil {
+			return nil, fmt.Errorf("jwt: %s needs a private or a public key", k.name)
+		}
+		k.verify = jwt.NewRS256(jwt.RSAPublicKey(pk))
+		k.pub = pk
+	case JwtES256:
+		var pk *ecdsa.PublicKey
+		if priv != nil {
+			ek, ok := priv.(*ecdsa.PrivateKey)
+			if !ok || ek.Curve != elliptic.P256() {
+				return nil, fmt.Errorf("jwt: %s needs a P-256 ECDSA private key", k.name)
+			}
+			k.signer = jwt.NewES256(jwt.ECDSAPrivateKey(ek))
+			pk = &ek.PublicKey
+		}
+		if pub != nil {
+			if pk, _ = pub.(*ecdsa.PublicKey); pk == nil || pk.Curve != elliptic.P256() {
+				return nil, fmt.Errorf("jwt: %s needs a P-256 ECDSA public key", k.name)
+			}
+		}
+		if pk == nil {
+			return nil, fmt.Errorf("jwt: %s needs a private or a public key", k.name)
+		}
+		k.verify = jwt.NewES256(jwt.ECDSAPublicKey(pk))
+		k.pub = pk
+	case JwtEdDSA:
+		var pk ed25519.PublicKey
+		if priv != nil {
+			ek, ok := priv.(ed25519.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("jwt: %s needs an Ed25519 private key", k.name)
+			}
+			k.signer = jwt.NewEd25519(jwt.Ed25519PrivateKey(ek))
+			pk = ek.Public().(ed25519.PublicKey)
+		}
+		if pub != nil {
+			if pk, _ = pub.(ed25519.PublicKey); pk == nil {
+				return nil, fmt.Errorf("jwt: %s needs an Ed25519 public key", k.name)
+			}
+		}
+		if pk == nil {
+			return nil, fmt.Errorf("jwt: %s needs a private or a public key", k.name)
+		}
+		k.verify = jwt.NewEd25519(jwt.Ed25519PublicKey(pk))
+		k.pub = pk
+	default:
+		return nil, fmt.Errorf("jwt: unsupported algorithm %s", k.name)
+	}
+	return k, nil
+}
+
+// HmacKey creates an HS256 key from a shared secret
+func HmacKey(secretKey string) *JwtKey {
+	return &JwtKey{
+		name:   JwtHS256,
+		signer: jwt.NewHS256([]byte(secretKey)),
+		verify: jwt.NewHS256([]byte(secretKey)),
+	}
+}
+
+// Algorithm returns the name of the algorithm of the key
+func (k *JwtKey) Algorithm() string {
+	return k.name
+}
+
+// KeyID returns the key id of the key
+func (k *JwtKey) KeyID() string {
+	return k.kid
+}
+
+// SigningAlgorithm returns the algorithm and key id to sign tokens with.
+// It fails if the key has no private key.
+func (k *JwtKey) SigningAlgorithm() (jwt.Algorithm, string, error) {
+	if k.signer == nil {
+		return nil, "", ErrJwtNoSigningKey
+	}
+	return k.signer, k.kid, nil
+}
+
+// VerifyingAlgorithm returns the algorithm to verify a token with.
+// It fails if the token was signed with another algorithm, or has another key id.
+func (k *JwtKey) VerifyingAlgorithm(hdr jwt.Header) (jwt.Algorithm, error) {
+	if hdr.Algorithm != k.name {
+		return nil, fmt.Errorf("%w %q", ErrJwtAlgorithm, hdr.Algorithm)
+	}
+	if k.kid != "" && hdr.KeyID != "" && hdr.KeyID != k.kid {
+		return nil, fmt.Errorf("%w %q", ErrJwtKeyID, hdr.KeyID)
+	}
+	return k.verify, nil
+}
+
+// SignJwtUsing builds a JWT token signed with the algorithm and key of the signer.
+// The claims are converted as SignJwt does.
+func SignJwtUsing(claims *map[string]interface{}, signer JwtSigner) (string, error) {
+	if claims == nil {
+		return "", fmt.Errorf(`claims not set`)
+	}
+	pl, err := jwtClaims(*claims)
+	if err != nil {
+		return "", err
+	}
+	return signJwt(pl, signer)
+}
+
+// ValidateJwtUsing validates the bearer token of the request with a verifier and returns its information
+func ValidateJwtUsing(r *http.Request, verifier JwtVerifier, validateTimes bool) (*JWTInfo, error) {
+	token, err := bearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJwtUsing(token, verifier, validateTimes)
+}
+
+// ParseJwtUsing validates and parses a JWT with a verifier and returns its information
+func ParseJwtUsing(token string, verifier JwtVerifier, validateTimes bool) (*JWTInfo, error) {
+	return ParseJwtWithOptions(token, verifier, JwtValidationOptions{SkipTimes: !validateTimes})
+}
+
+// ParseJwtInto validates and parses a JWT with a verifier, decodes its claims into
+// a caller-supplied claims struct and returns its information. The struct can have
+// custom claims such as roles, scopes or permissions:
+//
+//	type AppClaims struct {
+//		jwt.Payload
+//		Roles []string `json:"roles"`
+//	}
+//	var claims AppClaims
+//	info, err := stdutil.ParseJwtInto(token, key, true, &claims)
+func ParseJwtInto[T any](token string, verifier JwtVerifier, validateTimes bool, claims *T) (*JWTInfo, error) {
+	return ParseJwtIntoWithOptions(token, verifier, JwtValidationOptions{SkipTimes: !validateTimes}, claims)
+}
+
+// GetRequestVarsUsing requests variables and returns the result of validating the JWT with a verifier
+func GetRequestVarsUsing(r *http.Request, verifier JwtVerifier, validateTimes bool) (RequestVars, error) {
+	return getRequestVars(r, func() (*JWTInfo, error) {
+		return ValidateJwtUsing(r, verifier, validateTimes)
+	})
+}
+
+// jwtClaimSet keeps the claims of a token decoded as a CustomPayload, as a map and as raw JSON
+type jwtClaimSet struct {
+	CustomPayload
+	claims map[string]any
+	raw    []byte
+}
+
+// UnmarshalJSON decodes the claims of a token
+func (cs *jwtClaimSet) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &cs.CustomPayload); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &cs.claims); err != nil {
+		return err
+	}
+	cs.raw = append([]byte(nil), b...)
+	return nil
+}
+
+// info returns the information of a token
+func (cs *jwtClaimSet) info(token string) *JWTInfo {
+	tm := func(t *jwt.Time) time.Time {
+		if t == nil {
+			return time.Time{}
+		}
+		return t.Time
+	}
+	return &JWTInfo{
+		ApplicationID:  cs.ApplicationID,
+		Audience:       cs.Audience,
+		Claims:         cs.claims,
+		DeviceID:       cs.DeviceID,
+		Domain:         cs.Domain,
+		ExpirationTime: tm(cs.ExpirationTime),
+		IssuedAt:       tm(cs.IssuedAt),
+		Issuer:         cs.Issuer,
+		JWTID:          cs.JWTID,
+		NotBefore:      tm(cs.NotBefore),
+		Raw:            token,
+		Subject:        cs.Subject,
+		TenantID:       cs.TenantID,
+		UserName:       cs.UserName,
+		Valid:          true,
+	}
+}
+
+// parseJwt verifies a JWT with a verifier, decodes its claims and validates them
+func parseJwt(token string, verifier JwtVerifier, vo JwtValidationOptions) (*jwtClaimSet, error) {
+	if verifier == nil {
+		return nil, fmt.Errorf(`jwt verifier not set`)
+	}
+	var cs jwtClaimSet
+	alg := &jwtutil.Resolver{New: verifier.VerifyingAlgorithm}
+	if _, err := jwt.Verify([]byte(token), alg, &cs, jwt.ValidateHeader); err != nil {
+		return nil, err
+	}
+	if err := vo.validate(&cs, time.Now()); err != nil {
+		return nil, err
+	}
+	return &cs, nil
+}
+
+// signJwt signs a payload with the algorithm and key of the signer
+func signJwt(payload any, signer JwtSigner) (string, error) {
+	if signer == nil {
+		return "", fmt.Errorf(`jwt signer not set`)
+	}
+	alg, kid, err := signer.SigningAlgorithm()
+	if err != nil {
+		return "", err
+	}
+	var opts []jwt.SignOption
+	if kid != "" {
+		opts = append(opts, jwt.KeyID(kid))
+	}
+	token, err := jwt.Sign(payload, alg, opts...)
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+// bearerToken returns the bearer token of the Authorization header of a request
+func bearerToken(r *http.Request) (string, error) {
+	var (
+		jwtfromck,
+		jwth string
+		jwtp []string
+	)
+	if jwth = r.Header.Get("Authorization"); len(jwth) == 0 {
+		return "", fmt.Errorf(`authorization header not set`)
+	}
+	if jwtp = strings.Split(jwth, " "); len(jwtp) < 2 {
+		return "", fmt.Errorf(`invalid authorization header`)
+	}
+	if !strings.EqualFold(strings.TrimSpace(jwtp[0]), "bearer") {
+		return "", fmt.Errorf(`invalid authorization bearer`)
+	}
+	if jwtfromck = strings.TrimSpace(jwtp[1]); len(jwtfromck) == 0 {
+		return "", fmt.Errorf(`invalid authorization token`)
+	}
+	return jwtfromck, nil
+}
+
+// parsePrivateKeyPEM parses a PKCS #8, PKCS #1 (RSA) or SEC 1 (EC) PEM encoded private key
+func parsePrivateKeyPEM(b []byte) (crypto.PrivateKey, error) {
+	blk, _ := pem.Decode(b)
+	if blk == nil {
+		return nil, errors.New(`jwt: invalid PEM private key`)
+	}
+	switch blk.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(blk.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(blk.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(blk.Bytes)
+	}
+	return nil, fmt.Errorf("jwt: unsupported PEM block %q", blk.Type)
+}
+
+// parsePublicKeyPEM parses a PKIX or PKCS #1 (RSA) PEM encoded public key, or the public key of a certificate
+func parsePublicKeyPEM(b []byte) (crypto.PublicKey, error) {
+	blk, _ := pem.Decode(b)
+	if blk == nil {
+		return nil, errors.New(`jwt: invalid PEM public key`)
+	}
+	switch blk.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(blk.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(blk.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(blk.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return nil, fmt.Errorf("jwt: unsupported PEM block %q", blk.Type)
+}
@@ -0,0 +1,94 @@
+package stdutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// pemKeys returns a PEM encoded PKCS #8 private key and PKIX public key
+func pemKeys(t *testing.T, priv any, pub any) ([]byte, []byte) {
+	t.Helper()
+	pb, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ub, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pb}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ub})
+}
+
+func TestJwtKeys(t *testing.T) {
+	rk, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ek, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	epub, edk, _ := ed25519.GenerateKey(rand.Reader)
+	keys := map[string][2]any{
+		JwtRS256: {rk, &rk.PublicKey},
+		JwtES256: {ek, &ek.PublicKey},
+		JwtEdDSA: {edk, epub},
+	}
+	claims := map[string]interface{}{
+		"usr": "ann",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for alg, kp := range keys {
+		priv, pub := pemKeys(t, kp[0], kp[1])
+		signer, err := NewJwtKey(JwtOptions{Algorithm: alg, PrivateKey: priv, KeyID: "k1"})
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		verifier, err := NewJwtKey(JwtOptions{Algorithm: alg, PublicKey: pub, KeyID: "k1"})
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if _, err = SignJwtUsing(&claims, verifier); !errors.Is(err, ErrJwtNoSigningKey) {
+			t.Errorf("%s: expected ErrJwtNoSigningKey, got %v", alg, err)
+		}
+		token, err := SignJwtUsing(&claims, signer)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		ji, err := ValidateJwtUsing(r, verifier, true)
+		if err != nil || ji.UserName != "ann" || !ji.Valid {
+			t.Errorf("%s: unexpected token info %+v %v", alg, ji, err)
+		}
+
+		// Another key id or algorithm is rejected
+		other, _ := NewJwtKey(JwtOptions{Algorithm: alg, PublicKey: pub, KeyID: "k2"})
+		if _, err = ParseJwtUsing(token, other, true); !errors.Is(err, ErrJwtKeyID) {
+			t.Errorf("%s: expected ErrJwtKeyID, got %v", alg, err)
+		}
+		if _, err = ParseJwt(token, string(pub), true); !errors.Is(err, ErrJwtAlgorithm) {
+			t.Errorf("%s: expected ErrJwtAlgorithm, got %v", alg, err)
+		}
+	}
+
+	// A token signed with the public key as an HMAC secret must not verify
+	priv, pub := pemKeys(t, rk, &rk.PublicKey)
+	forged, _ := SignJwtUsing(&claims, HmacKey(string(pub)))
+	verifier, _ := NewJwtKey(JwtOptions{Algorithm: JwtRS256, PublicKey: pub})
+	if _, err := ParseJwtUsing(forged, verifier, false); !errors.Is(err, ErrJwtAlgorithm) {
+		t.Errorf("expected ErrJwtAlgorithm, got %v", err)
+	}
+
+	// Mismatched key types are rejected
+	if _, err := NewJwtKey(JwtOptions{Algorithm: JwtES256, PrivateKey: priv}); err == nil {
+		t.Error("expected an error for an RSA key with ES256")
+	}
+	if _, err := NewJwtKey(JwtOptions{Algorithm: JwtRS256}); err == nil {
+		t.Error("expected an error without keys")
+	}
+}
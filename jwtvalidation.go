@@ -0,0 +1,114 @@
+package stdutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+)
+
+// Errors of validating the claims of a token.
+// Errors of the registered claims also match the errors of the jwt package, such as jwt.ErrExpValidation.
+var (
+	ErrTokenExpired        = errors.New(`jwt: token expired`)                   // The token is expired or has no expiration time
+	ErrTokenNotYetValid    = errors.New(`jwt: token not valid yet`)             // The not before time of the token is in the future
+	ErrTokenIssuedInFuture = errors.New(`jwt: token issued in the future`)      // The issued at time of the token is in the future
+	ErrTokenTooOld         = errors.New(`jwt: token too old`)                   // The token was issued before the maximum age
+	ErrIssuerMismatch      = errors.New(`jwt: unexpected issuer`)               // The issuer of the token is not an expected one
+	ErrAudienceMismatch    = errors.New(`jwt: token not intended for audience`) // The token is not intended for a required audience
+	ErrClaimMissing        = errors.New(`jwt: missing claim`)                   // A required claim is not in the token
+)
+
+// JwtValidationOptions are the checks of the claims of a token
+type JwtValidationOptions struct {
+	Issuers        []string      // Expected issuers, one of which must have issued the token. Any issuer if not set
+	Audience       []string      // Required audience, at least one of which the token must be intended for. Any audience if not set
+	Leeway         time.Duration // Allowed clock skew when checking exp, nbf, iat and the maximum age
+	MaxAge         time.Duration // Maximum time since the token was issued. Requires the iat claim if set
+	RequiredClaims []string      // Claims that must be in the token
+	SkipTimes      bool          // Do not check exp, nbf and iat. The maximum age is still checked
+}
+
+// ParseJwtWithOptions validates and parses a JWT with a verifier, checks its claims
+// with the validation options and returns its information
+func ParseJwtWithOptions(token string, verifier JwtVerifier, vo JwtValidationOptions) (*JWTInfo, error) {
+	cs, err := parseJwt(token, verifier, vo)
+	if err != nil {
+		return nil, err
+	}
+	return cs.info(token), nil
+}
+
+// ParseJwtIntoWithOptions validates and parses a JWT with a verifier, checks its claims
+// with the validation options and decodes them into a caller-supplied claims struct
+func ParseJwtIntoWithOptions[T any](token string, verifier JwtVerifier, vo JwtValidationOptions, claims *T) (*JWTInfo, error) {
+	if claims == nil {
+		return nil, fmt.Errorf(`claims not set`)
+	}
+	cs, err := parseJwt(token, verifier, vo)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(cs.raw, claims); err != nil {
+		return nil, err
+	}
+	return cs.info(token), nil
+}
+
+// ValidateJwtWithOptions validates the JWT of the Authorization header with a verifier and the validation options
+func ValidateJwtWithOptions(r *http.Request, verifier JwtVerifier, vo JwtValidationOptions) (*JWTInfo, error) {
+	token, err := bearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJwtWithOptions(token, verifier, vo)
+}
+
+// GetRequestVarsWithOptions requests variables and returns the result of validating the JWT
+// with a verifier and the validation options
+func GetRequestVarsWithOptions(r *http.Request, verifier JwtVerifier, vo JwtValidationOptions) (RequestVars, error) {
+	return getRequestVars(r, func() (*JWTInfo, error) {
+		return ValidateJwtWithOptions(r, verifier, vo)
+	})
+}
+
+// validate checks the claims of a token at a time
+func (vo JwtValidationOptions) validate(cs *jwtClaimSet, now time.Time) error {
+	// Times are compared in whole seconds, as numeric dates are
+	now = jwt.NumericDate(now).Time
+	if !vo.SkipTimes {
+		if cs.ExpirationTime == nil || now.After(cs.ExpirationTime.Add(vo.Leeway)) {
+			return fmt.Errorf("%w: %w", ErrTokenExpired, jwt.ErrExpValidation)
+		}
+		if cs.NotBefore != nil && now.Add(vo.Leeway).Before(cs.NotBefore.Time) {
+			return fmt.Errorf("%w: %w", ErrTokenNotYetValid, jwt.ErrNbfValidation)
+		}
+		if cs.IssuedAt != nil && now.Add(vo.Leeway).Before(cs.IssuedAt.Time) {
+			return fmt.Errorf("%w: %w", ErrTokenIssuedInFuture, jwt.ErrIatValidation)
+		}
+	}
+	if vo.MaxAge > 0 {
+		if cs.IssuedAt == nil {
+			return fmt.Errorf("%w: iat", ErrClaimMissing)
+		}
+		if now.Sub(cs.IssuedAt.Time) > vo.MaxAge+vo.Leeway {
+			return fmt.Errorf("%w: %w", ErrTokenTooOld, jwt.ErrIatValidation)
+		}
+	}
+	if len(vo.Issuers) > 0 && !slices.Contains(vo.Issuers, cs.Issuer) {
+		return fmt.Errorf("%w %q: %w", ErrIssuerMismatch, cs.Issuer, jwt.ErrIssValidation)
+	}
+	if len(vo.Audience) > 0 && jwt.AudienceValidator(vo.Audience)(&cs.Payload) != nil {
+		return fmt.Errorf("%w: %w", ErrAudienceMismatch, jwt.ErrAudValidation)
+	}
+	for _, name := range vo.RequiredClaims {
+		if _, ok := cs.claims[name]; !ok {
+			return fmt.Errorf("%w: %s", ErrClaimMissing, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package stdutil
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+)
+
+func TestJwtValidationOptions(t *testing.T) {
+	key := HmacKey("secret")
+	now := time.Now()
+	sign := func(b *ClaimsBuilder) string {
+		t.Helper()
+		token, err := b.Sign(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := func() *ClaimsBuilder {
+		return NewClaims().Issuer("auth").Audience("billing").ExpiresIn(time.Hour)
+	}
+
+	for name, tc := range map[string]struct {
+		token string
+		vo    JwtValidationOptions
+		want  error
+	}{
+		"valid":           {sign(valid()), JwtValidationOptions{Issuers: []string{"other", "auth"}, Audience: []string{"billing"}}, nil},
+		"expired":         {sign(valid().ExpiresAt(now.Add(-time.Minute))), JwtValidationOptions{}, ErrTokenExpired},
+		"expired leeway":  {sign(valid().ExpiresAt(now.Add(-time.Minute))), JwtValidationOptions{Leeway: 2 * time.Minute}, nil},
+		"no expiration":   {sign(NewClaims()), JwtValidationOptions{}, ErrTokenExpired},
+		"skip times":      {sign(NewClaims()), JwtValidationOptions{SkipTimes: true}, nil},
+		"not yet valid":   {sign(valid().NotBefore(now.Add(time.Minute))), JwtValidationOptions{}, ErrTokenNotYetValid},
+		"nbf leeway":      {sign(valid().NotBefore(now.Add(time.Minute))), JwtValidationOptions{Leeway: 2 * time.Minute}, nil},
+		"issued ahead":    {sign(valid().IssuedAt(now.Add(time.Minute))), JwtValidationOptions{}, ErrTokenIssuedInFuture},
+		"too old":         {sign(valid().IssuedAt(now.Add(-time.Hour))), JwtValidationOptions{MaxAge: 30 * time.Minute}, ErrTokenTooOld},
+		"max age":         {sign(valid().IssuedAt(now.Add(-time.Minute))), JwtValidationOptions{MaxAge: 30 * time.Minute}, nil},
+		"issuer":          {sign(valid()), JwtValidationOptions{Issuers: []string{"other"}}, ErrIssuerMismatch},
+		"audience":        {sign(valid()), JwtValidationOptions{Audience: []string{"payroll"}}, ErrAudienceMismatch},
+		"required claim":  {sign(valid()), JwtValidationOptions{RequiredClaims: []string{"roles"}}, ErrClaimMissing},
+		"required claims": {sign(valid().Set("roles", []string{"admin"})), JwtValidationOptions{RequiredClaims: []string{"iss", "roles"}}, nil},
+	} {
+		_, err := ParseJwtWithOptions(tc.token, key, tc.vo)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, err)
+		}
+	}
+
+	// Errors still match the errors of the jwt package
+	if _, err := ParseJwtUsing(sign(NewClaims()), key, true); !errors.Is(err, ErrTokenExpired) || !errors.Is(err, jwt.ErrExpValidation) {
+		t.Errorf("expected an expiration error, got %v", err)
+	}
+
+	var c Claims
+	info, err := ParseJwtIntoWithOptions(sign(valid().Set("scope", "read")), key, JwtValidationOptions{Audience: []string{"billing"}}, &c)
+	if err != nil || c.Extra["scope"] != "read" || info.Issuer != "auth" {
+		t.Errorf("unexpected claims %+v, info %+v, error %v", c, info, err)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+sign(valid()))
+	if _, err = ValidateJwtWithOptions(r, key, JwtValidationOptions{Issuers: []string{"other"}}); !errors.Is(err, ErrIssuerMismatch) {
+		t.Errorf("expected ErrIssuerMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package stdutil
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// PageOptions sets how the pages of an API endpoint are requested
+type PageOptions struct {
+	PageParam   string // Query parameter of the page number. Default: "page"
+	SizeParam   string // Query parameter of the page size. Default: "page_size"
+	PageSize    int    // Number of items per page. If zero, the page size is not sent
+	FirstPage   *int   // Number of the first page, such as 0 for zero-based pages. Default: 1
+	MaxPages    int    // Maximum number of pages to read. Default: no limit
+	Concurrency int    // Maximum number of pages read in parallel once the page count is known. Default: 1
+	first       int    // Number of the first page, set from FirstPage
+}
+
+// ReadAllPages reads the pages of an API endpoint and merges their data.
+//
+// Pages are requested with the page number query parameter until the page of the
+// result reaches its page count. If the endpoint does not return a page count, reading
+// stops at the first empty page, or at the first page with less items than the page size.
+//
+// Reading stops early on a result that is not OK, which is returned with the data merged so far.
+// Otherwise, the result of the last page is returned with the data of all pages.
+func ReadAllPages[T any](ctx context.Context, endPoint string, po PageOptions, opts ...RequestOption) ResultAny[[]T] {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	po = po.withDefaults()
+	data := make([]T, 0)
+	page := po.first
+	res := readPage[T](ctx, endPoint, po, page, opts)
+	data = append(data, res.Data...)
+	if !res.OK() || !po.hasMore(res.Result, page, len(res.Data)) {
+		res.Data = data
+		return res
+	}
+
+	// Once the page count is known, the remaining pages can be read in parallel
+	if res.PageCount != nil && po.Concurrency > 1 {
+		last := po.lastPage(*res.PageCount)
+		results := readPages[T](ctx, endPoint, po, page+1, last, opts)
+		for _, r := range results {
+			data = append(data, r.Data...)
+			res = r
+			if !r.OK() {
+				break
+			}
+		}
+		res.Data = data
+		return res
+	}
+
+	for {
+		page++
+		res = readPage[T](ctx, endPoint, po, page, opts)
+		data = append(data, res.Data...)
+		if !res.OK() || !po.hasMore(res.Result, page, len(res.Data)) {
+			break
+		}
+	}
+	res.Data = data
+	return res
+}
+
+// readPages reads a range of pages in parallel, up to the concurrency of the page options.
+// The results are returned in page order. Pages after a failed page are not read.
+func readPages[T any](ctx context.Context, endPoint string, po PageOptions, first, last int, opts []RequestOption) []ResultAny[[]T] {
+	if last < first {
+		return nil
+	}
+	results := make([]ResultAny[[]T], last-first+1)
+	read := 0 // number of pages started
+	sem := make(chan struct{}, po.Concurrency)
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	failed := last + 1 // lowest failed page
+	for p := first; p <= last; p++ {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := p > failed
+		mu.Unlock()
+		if stop || ctx.Err() != nil {
+			<-sem
+			break
+		}
+		read++
+		wg.Add(1)
+		go func(p int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			r := readPage[T](ctx, endPoint, po, p, opts)
+			mu.Lock()
+			defer mu.Unlock()
+			if !r.OK() && p < failed {
+				failed = p
+			}
+			results[p-first] = r
+		}(p)
+	}
+	wg.Wait()
+	return results[:read]
+}
+
+// readPage reads a single page of an API endpoint
+func readPage[T any](ctx context.Context, endPoint string, po PageOptions, page int, opts []RequestOption) ResultAny[[]T] {
+	u, err := url.Parse(endPoint)
+	if err != nil {
+		return clientError[[]T](err)
+	}
+	qv := u.Query()
+	qv.Set(po.PageParam, strconv.Itoa(page))
+	if po.PageSize > 0 {
+		qv.Set(po.SizeParam, strconv.Itoa(po.PageSize))
+	}
+	u.RawQuery = qv.Encode()
+	return ReadApiCtx[[]T](ctx, u.String(), opts...)
+}
+
+// withDefaults sets the defaults of the unset page options
+func (po PageOptions) withDefaults() PageOptions {
+	if po.PageParam == "" {
+		po.PageParam = "page"
+	}
+	if po.SizeParam == "" {
+		po.SizeParam = "page_size"
+	}
+	po.first = 1
+	if po.FirstPage != nil {
+		po.first = *po.FirstPage
+	}
+	if po.Concurrency < 1 {
+		po.Concurrency = 1
+	}
+	return po
+}
+
+// lastPage returns the last page to read from the page count
+func (po PageOptions) lastPage(pageCount int) int {
+	last := pageCount + po.first - 1
+	if po.MaxPages > 0 && last > po.first+po.MaxPages-1 {
+		last = po.first + po.MaxPages - 1
+	}
+	return last
+}
+
+// hasMore checks if there are pages after the page just read. The page requested is
+// compared with the page count, as servers ignoring the page parameter keep reporting the same page.
+func (po PageOptions) hasMore(res Result, page int, count int) bool {
+	if po.MaxPages > 0 && page-po.first+1 >= po.MaxPages {
+		return false
+	}
+	if res.PageCount != nil {
+		return page < po.lastPage(*res.PageCount)
+	}
+	return count > 0 && (po.PageSize == 0 || count >= po.PageSize)
+}
@@ -0,0 +1,26 @@
+//go:build go1.23
+
+package stdutil
+
+import (
+	"context"
+	"iter"
+)
+
+// ReadPages returns an iterator over the pages of an API endpoint, yielding the page number
+// and its result. Pages are read one at a time as they are iterated, following the same rules
+// as ReadAllPages. The iteration ends after a result that is not OK.
+func ReadPages[T any](ctx context.Context, endPoint string, po PageOptions, opts ...RequestOption) iter.Seq2[int, ResultAny[[]T]] {
+	return func(yield func(int, ResultAny[[]T]) bool) {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		po := po.withDefaults()
+		for page := po.first; ; page++ {
+			res := readPage[T](ctx, endPoint, po, page, opts)
+			if !yield(page, res) || !res.OK() || !po.hasMore(res.Result, page, len(res.Data)) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+//go:build go1.23
+
+package stdutil
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReadPages(t *testing.T) {
+	srv := pageServer(12, true, 0, nil, nil)
+	defer srv.Close()
+	pages, items := 0, 0
+	for page, res := range ReadPages[int](context.Background(), srv.URL, PageOptions{PageSize: 5}) {
+		if !res.OK() {
+			t.Fatalf("page %d: unexpected result %s", page, res.Status)
+		}
+		pages++
+		items += len(res.Data)
+	}
+	if pages != 3 || items != 12 {
+		t.Errorf("expected 3 pages and 12 items, got %d and %d", pages, items)
+	}
+
+	// Breaking out of the loop stops reading
+	pages = 0
+	for range ReadPages[int](context.Background(), srv.URL, PageOptions{PageSize: 5}) {
+		pages++
+		break
+	}
+	if pages != 1 {
+		t.Errorf("expected 1 page, got %d", pages)
+	}
+}
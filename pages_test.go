@@ -0,0 +1,128 @@
+package stdutil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// pageServer serves the items 1 to total in pages, failing on the failPage if set
+func pageServer(total int, withCount bool, failPage int, inflight *int32, maxInflight *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inflight != nil {
+			n := atomic.AddInt32(inflight, 1)
+			defer atomic.AddInt32(inflight, -1)
+			for {
+				m := atomic.LoadInt32(maxInflight)
+				if n <= m || atomic.CompareAndSwapInt32(maxInflight, m, n) {
+					break
+				}
+			}
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+		if page == failPage {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]any{"status": "EXCEPTION", "messages": []string{"ERR: page failed"}})
+			return
+		}
+		items := []int{}
+		for i := (page-1)*size + 1; i <= page*size && i <= total; i++ {
+			items = append(items, i)
+		}
+		res := map[string]any{"status": "OK", "data": items, "page": page, "page_size": size}
+		if withCount {
+			res["page_count"] = (total + size - 1) / size
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+}
+
+func TestReadAllPages(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		withCount   bool
+		concurrency int
+	}{
+		{"page count", true, 1},
+		{"no page count", false, 1},
+		{"parallel", true, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var inflight, maxInflight int32
+			srv := pageServer(23, tc.withCount, 0, &inflight, &maxInflight)
+			defer srv.Close()
+			res := ReadAllPages[int](context.Background(), srv.URL+"/items?sort=id", PageOptions{
+				PageSize:    5,
+				Concurrency: tc.concurrency,
+			})
+			if !res.OK() {
+				t.Fatalf("unexpected result %s %v", res.Status, res.Messages)
+			}
+			if len(res.Data) != 23 {
+				t.Fatalf("expected 23 items, got %d", len(res.Data))
+			}
+			for i, v := range res.Data {
+				if v != i+1 {
+					t.Fatalf("item %d out of order: %d", i, v)
+				}
+			}
+			if maxInflight > int32(tc.concurrency) {
+				t.Errorf("expected at most %d pages in parallel, got %d", tc.concurrency, maxInflight)
+			}
+		})
+	}
+}
+
+func TestReadAllPagesException(t *testing.T) {
+	for _, concurrency := range []int{1, 2} {
+		srv := pageServer(50, true, 3, nil, nil)
+		res := ReadAllPages[int](context.Background(), srv.URL, PageOptions{PageSize: 5, Concurrency: concurrency})
+		srv.Close()
+		if res.Status != string(EXCEPTION) {
+			t.Fatalf("expected EXCEPTION, got %s", res.Status)
+		}
+		if len(res.Data) != 10 {
+			t.Errorf("expected the 10 items before the failed page, got %d", len(res.Data))
+		}
+	}
+}
+
+func TestReadAllPagesMaxPages(t *testing.T) {
+	srv := pageServer(50, true, 0, nil, nil)
+	defer srv.Close()
+	res := ReadAllPages[int](context.Background(), srv.URL, PageOptions{PageSize: 5, MaxPages: 2})
+	if !res.OK() || len(res.Data) != 10 {
+		t.Errorf("expected 10 items, got %s %d", res.Status, len(res.Data))
+	}
+}
+
+func TestReadAllPagesNumbering(t *testing.T) {
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Query().Get("page"))
+		// The page parameter is ignored, so the same page is always reported
+		json.NewEncoder(w).Encode(map[string]any{"status": "OK", "data": []int{1}, "page": 1, "page_count": 3})
+	}))
+	defer srv.Close()
+
+	zero := 0
+	for _, tc := range []struct {
+		first *int
+		want  string
+	}{
+		{nil, "1,2,3"},
+		{&zero, "0,1,2"},
+	} {
+		requested = nil
+		res := ReadAllPages[int](context.Background(), srv.URL, PageOptions{FirstPage: tc.first})
+		if !res.OK() || len(res.Data) != 3 || strings.Join(requested, ",") != tc.want {
+			t.Errorf("expected pages %s, got %s %d items of pages %v", tc.want, res.Status, len(res.Data), requested)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package stdutil
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/narsilworks/livenote"
+)
+
+// ProblemContentType is the content type of RFC 7807 problem documents
+const ProblemContentType = "application/problem+json"
+
+// ProblemDetails is an RFC 7807 problem document.
+//
+// Members other than the standard ones are kept in Extensions. When converted
+// from a Result, the messages, structured messages and focus control are carried
+// in the "messages", "entries" and "focus_control" extensions.
+type ProblemDetails struct {
+	Type       string         `json:"type,omitempty"`     // URI reference of the problem type. Default: about:blank
+	Title      string         `json:"title,omitempty"`    // Short summary of the problem type
+	Status     int            `json:"status,omitempty"`   // HTTP status code of the problem
+	Detail     string         `json:"detail,omitempty"`   // Explanation of this occurrence of the problem
+	Instance   string         `json:"instance,omitempty"` // URI reference of this occurrence of the problem
+	Extensions map[string]any `json:"-"`                  // Extension members
+}
+
+// problemMembers are the standard members of a problem document
+var problemMembers = []string{"type", "title", "status", "detail", "instance"}
+
+// ToProblem converts the result to a problem document with an HTTP status code.
+// If the status code is 0, it is mapped from the status of the result by the DefaultResultWriter.
+// The detail is the first error message of the result, or the first message if there are no errors.
+func (r *Result) ToProblem(statusCode int) ProblemDetails {
+	if statusCode == 0 {
+		statusCode = DefaultResultWriter.StatusCode(Status(r.Status))
+	}
+	p := ProblemDetails{
+		Type:       "about:blank",
+		Title:      http.StatusText(statusCode),
+		Status:     statusCode,
+		Extensions: make(map[string]any),
+	}
+	for _, m := range r.Messages {
+		note := ParseMessage(m)
+		if note.Type == livenote.Error || note.Type == livenote.Fatal {
+			p.Detail = note.Message
+			break
+		}
+		if p.Detail == "" {
+			p.Detail = note.Message
+		}
+	}
+	if len(r.Messages) > 0 {
+		p.Extensions["messages"] = r.Messages
+	}
+	if len(r.Entries) > 0 {
+		p.Extensions["entries"] = r.Entries
+	}
+	if r.FocusControl != nil {
+		p.Extensions["focus_control"] = *r.FocusControl
+	}
+	return p
+}
+
+// ToResult converts the problem document to an EXCEPTION result of the calling function.
+// The messages extension becomes the messages of the result. Without it, the
+// title and the detail of the problem are added as an error message.
+func (p *ProblemDetails) ToResult() Result {
+	rd := ResultData{Result: newResult(callerName(2))}
+	rd.assignProblem(p)
+	return rd.Result
+}
+
+// WriteProblem writes a problem document as an application/problem+json response.
+// The HTTP status code is the status of the problem, or 500 if it is not set.
+func WriteProblem(w http.ResponseWriter, p ProblemDetails) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	code := p.Status
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.WriteHeader(code)
+	_, err = w.Write(b)
+	return err
+}
+
+// MarshalJSON encodes the problem document with its extensions as top-level members
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	for _, k := range problemMembers {
+		delete(m, k)
+	}
+	if p.Type != "" {
+		m["type"] = p.Type
+	}
+	if p.Title != "" {
+		m["title"] = p.Title
+	}
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes a problem document, keeping the members other than the standard ones as extensions
+func (p *ProblemDetails) UnmarshalJSON(b []byte) error {
+	type problem ProblemDetails
+	var pd problem
+	if err := json.Unmarshal(b, &pd); err != nil {
+		return err
+	}
+	m := make(map[string]any)
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	for _, k := range problemMembers {
+		delete(m, k)
+	}
+	*p = ProblemDetails(pd)
+	p.Extensions = nil
+	if len(m) > 0 {
+		p.Extensions = m
+	}
+	return nil
+}
+
+// assignProblem maps a problem document onto the result data
+func (rd *ResultData) assignProblem(p *ProblemDetails) {
+	rd.Return(EXCEPTION)
+	if fc, ok := p.Extensions["focus_control"].(string); ok {
+		rd.FocusControl = &fc
+	}
+	if ext, ok := p.Extensions["entries"]; ok {
+		// Extensions are decoded as generic values, so the entries are decoded again
+		var es []MessageEntry
+		if b, err := json.Marshal(ext); err == nil && json.Unmarshal(b, &es) == nil {
+			rd.Entries = append(rd.Entries, es...)
+		}
+	}
+	if msgs, ok := p.Extensions["messages"].([]any); ok && len(msgs) > 0 {
+		for _, m := range msgs {
+			if s, ok := m.(string); ok && s != "" {
+				rd.ln.Append(ParseMessage(s))
+			}
+		}
+		rd.updateMessage()
+		if rd.hasErrorNotes() {
+			return
+		}
+	}
+	msg := p.Title
+	if msg == "" {
+		msg = http.StatusText(p.Status)
+	}
+	if p.Detail != "" {
+		if msg != "" {
+			msg += ": "
+		}
+		msg += p.Detail
+	}
+	if msg == "" {
+		msg = "unknown problem"
+	}
+	rd.AddError("%s", msg)
+}
+
+// isProblem checks if a content type is the problem document content type
+func isProblem(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && strings.EqualFold(mt, ProblemContentType)
+}
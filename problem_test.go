@@ -0,0 +1,75 @@
+package stdutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProblemDetails(t *testing.T) {
+	res := InitResult()
+	fc := "email"
+	res.FocusControl = &fc
+	res.AddWarning("check the form")
+	res.AddError("email is taken")
+	p := res.ToProblem(0)
+	if p.Status != http.StatusInternalServerError || p.Detail != "email is taken" || p.Title != "Internal Server Error" {
+		t.Errorf("unexpected problem %+v", p)
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	json.Unmarshal(b, &m)
+	if m["focus_control"] != "email" || m["type"] != "about:blank" || len(m["messages"].([]any)) != 2 {
+		t.Errorf("unexpected document %s", b)
+	}
+
+	var p2 ProblemDetails
+	if err = json.Unmarshal(b, &p2); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p2.Extensions["status"]; ok {
+		t.Error("expected standard members not to be extensions")
+	}
+	res2 := p2.ToResult()
+	if res2.Status != string(EXCEPTION) || *res2.FocusControl != "email" || len(res2.Messages) != 2 || res2.Messages[1] != res.Messages[1] {
+		t.Errorf("unexpected result %+v", res2)
+	}
+	if res2.Operation != "testproblemdetails" {
+		t.Errorf("expected the operation of the caller, got %q", res2.Operation)
+	}
+}
+
+func TestProblemResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/third-party":
+			w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"type":"https://example.com/not-found","title":"Not Found","status":404,"detail":"user 5 does not exist","instance":"/users/5","trace":"abc"}`))
+		case "/ours":
+			res := InitResult()
+			res.AddError("quota exceeded")
+			WriteProblem(w, res.ToProblem(http.StatusTooManyRequests))
+		}
+	}))
+	defer srv.Close()
+
+	rd := ExecuteJsonApi("GET", srv.URL+"/third-party", nil, false, nil, 5, nil)
+	if rd.OK() || !strings.Contains(rd.MessagesToString(), "Not Found: user 5 does not exist") {
+		t.Errorf("unexpected result %s %s", rd.Status, rd.MessagesToString())
+	}
+	if rd.Problem == nil || rd.Problem.Instance != "/users/5" || rd.Problem.Extensions["trace"] != "abc" {
+		t.Errorf("unexpected problem %+v", rd.Problem)
+	}
+
+	res := ReadApi[any](srv.URL + "/ours")
+	if res.OK() || len(res.Messages) != 1 || !strings.HasSuffix(res.Messages[0], "quota exceeded") {
+		t.Errorf("unexpected result %s %v", res.Status, res.Messages)
+	}
+}
@@ -0,0 +1,193 @@
+package stdutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRateLimited     = errors.New(`rate limited`)                      // A fail fast rate limiter has no token for a request
+	errRateNotPositive = errors.New(`rate limit: rate must be positive`) // The rate of a rate limiter is zero or negative
+)
+
+type (
+	// RateLimiter limits the rate of outgoing requests with a token bucket.
+	//
+	// The bucket holds up to Burst tokens and is refilled at Rate tokens per second.
+	// Every request takes a token, waiting for one if the bucket is empty, or failing
+	// with ErrRateLimited if FailFast is set. Waiting ends early if the context is done.
+	//
+	// If PerHost is set, each host has its own bucket. Otherwise, all requests made
+	// with the limiter share a single bucket, as for the calls of an ApiClient.
+	//
+	// If Adaptive is set, requests wait for the delay of a Retry-After response header,
+	// and for the reset of the quota when the X-RateLimit-Remaining header reaches 0.
+	RateLimiter struct {
+		Rate     float64 // Tokens added per second
+		Burst    int     // Maximum number of tokens in the bucket. Default: 1
+		PerHost  bool    // Keep a bucket for each host
+		FailFast bool    // Fail with ErrRateLimited instead of waiting for a token
+		Adaptive bool    // Follow the Retry-After and X-RateLimit-* response headers
+		mu       sync.Mutex
+		buckets  map[string]*bucket
+	}
+	// bucket is a token bucket
+	bucket struct {
+		tokens float64   // available tokens. It is negative when tokens are reserved by waiting requests
+		last   time.Time // time of the last refill
+		until  time.Time // time requests are held until, from the response headers
+	}
+)
+
+// NewRateLimiter creates a rate limiter allowing a rate of requests per second with a burst.
+// The rate must be positive.
+func NewRateLimiter(rate float64, burst int) (*RateLimiter, error) {
+	if !(rate > 0) {
+		return nil, errRateNotPositive
+	}
+	return &RateLimiter{
+		Rate:  rate,
+		Burst: burst,
+	}, nil
+}
+
+// RateLimit sets the rate limiter of the request as an option
+//
+// This is used with <REST verb>Api functions
+func RateLimit(rl *RateLimiter) RequestOption {
+	return func(rp *RequestParam) error {
+		if rl != nil && !(rl.Rate > 0) {
+			return errRateNotPositive
+		}
+		rp.Limiter = rl
+		return nil
+	}
+}
+
+// Wait takes a token for a request to the host, waiting for it if needed.
+// It returns ErrRateLimited if the limiter fails fast and no token is available,
+// or the error of the context if it is done before a token is available.
+// It fails if the rate of the limiter is not positive.
+func (rl *RateLimiter) Wait(ctx context.Context, host string) error {
+	if !(rl.Rate > 0) {
+		return errRateNotPositive
+	}
+	rl.mu.Lock()
+	b := rl.bucket(host)
+	now := time.Now()
+	rl.refill(b, now)
+	wait := time.Duration(0)
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / rl.Rate * float64(time.Second))
+	}
+	if hold := b.until.Sub(now); hold > wait {
+		wait = hold
+	}
+	if wait > 0 && rl.FailFast {
+		rl.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrRateLimited, host)
+	}
+	b.tokens--
+	rl.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	if err := sleepCtx(ctx, wait); err != nil {
+		// Give back the token reserved for the request
+		rl.mu.Lock()
+		b.tokens++
+		rl.mu.Unlock()
+		return contextError(ctx, err)
+	}
+	return nil
+}
+
+// observe adapts the bucket of the host to the rate limit headers of a response
+func (rl *RateLimiter) observe(host string, header http.Header) {
+	if !rl.Adaptive || header == nil {
+		return
+	}
+	now := time.Now()
+	until := time.Time{}
+	if ra, ok := parseRetryAfter(header.Get("Retry-After")); ok {
+		until = now.Add(ra)
+	}
+	if rem, err := strconv.Atoi(strings.TrimSpace(header.Get("X-RateLimit-Remaining"))); err == nil && rem <= 0 {
+		if reset, ok := parseRateLimitReset(header.Get("X-RateLimit-Reset"), now); ok && reset.After(until) {
+			until = reset
+		}
+		rl.mu.Lock()
+		b := rl.bucket(host)
+		rl.refill(b, now)
+		if b.tokens > 0 {
+			b.tokens = 0
+		}
+		rl.mu.Unlock()
+	}
+	if until.IsZero() {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if b := rl.bucket(host); until.After(b.until) {
+		b.until = until
+	}
+}
+
+// bucket returns the bucket of the host, creating it full if needed
+func (rl *RateLimiter) bucket(host string) *bucket {
+	if !rl.PerHost {
+		host = ""
+	}
+	if rl.buckets == nil {
+		rl.buckets = make(map[string]*bucket)
+	}
+	b, ok := rl.buckets[host]
+	if !ok {
+		b = &bucket{
+			tokens: float64(rl.burst()),
+			last:   time.Now(),
+		}
+		rl.buckets[host] = b
+	}
+	return b
+}
+
+// refill adds the tokens accumulated since the last refill
+func (rl *RateLimiter) refill(b *bucket, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * rl.Rate
+		b.last = now
+	}
+	if maxt := float64(rl.burst()); b.tokens > maxt {
+		b.tokens = maxt
+	}
+}
+
+// burst returns the size of the bucket
+func (rl *RateLimiter) burst() int {
+	if rl.Burst <= 0 {
+		return 1
+	}
+	return rl.Burst
+}
+
+// parseRateLimitReset parses the X-RateLimit-Reset header in either seconds
+// until the reset or Unix time in seconds
+func parseRateLimitReset(value string, now time.Time) (time.Time, bool) {
+	secs, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || secs < 0 {
+		return time.Time{}, false
+	}
+	// Values this large cannot be a delay, and are taken as a Unix time
+	if secs > 1_000_000_000 {
+		return time.Unix(secs, 0), true
+	}
+	return now.Add(time.Duration(secs) * time.Second), true
+}
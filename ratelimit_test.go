@@ -0,0 +1,91 @@
+package stdutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("quota") == "out" {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", "2")
+		}
+		w.Write([]byte(`{"status":"OK"}`))
+	}))
+	defer srv.Close()
+
+	// Rates that are not positive
+	if _, err := NewRateLimiter(0, 1); err == nil {
+		t.Error("expected a zero rate to be rejected")
+	}
+	if err := (&RateLimiter{}).Wait(context.Background(), ""); err == nil {
+		t.Error("expected a zero rate limiter to fail")
+	}
+
+	// Blocking
+	rl, err := NewRateLimiter(20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if res := ReadApi[any](srv.URL, RateLimit(rl)); !res.OK() {
+			t.Fatalf("unexpected result %s %s", res.Status, res.MessagesToString())
+		}
+	}
+	if el := time.Since(start); el < 140*time.Millisecond {
+		t.Errorf("expected the calls to be spaced out, took %s", el)
+	}
+
+	// Fail fast
+	rl = &RateLimiter{Rate: 1, Burst: 2, FailFast: true}
+	for i := 0; i < 2; i++ {
+		if _, err := ExecuteApi("GET", srv.URL, nil, false, nil, 5, RateLimit(rl)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ExecuteApi("GET", srv.URL, nil, false, nil, 5, RateLimit(rl)); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+
+	// Streamed compressed bodies are not encoded when rate limited
+	before := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		body := io.MultiReader(strings.NewReader(`{"name":"value"}`))
+		if _, err := ExecuteApiStream(context.Background(), "POST", srv.URL, body, Compressed(true), RateLimit(rl)); !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("expected ErrRateLimited, got %v", err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expected no leaked goroutines, got %d more", n-before)
+	}
+
+	// Context cancellation
+	if rl, err = NewRateLimiter(0.1, 1); err != nil {
+		t.Fatal(err)
+	}
+	rl.Wait(context.Background(), "")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := ExecuteApiCtx(ctx, "GET", srv.URL, nil, false, nil, 5, RateLimit(rl)); !errors.Is(err, ErrRequestDeadline) {
+		t.Errorf("expected ErrRequestDeadline, got %v", err)
+	}
+
+	// Adaptive to the rate limit headers
+	rl = &RateLimiter{Rate: 100, Burst: 10, FailFast: true, Adaptive: true}
+	if _, err := ExecuteApi("GET", srv.URL+"?quota=out", nil, false, nil, 5, RateLimit(rl)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExecuteApi("GET", srv.URL, nil, false, nil, 5, RateLimit(rl)); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited after the quota ran out, got %v", err)
+	}
+}
@@ -2,21 +2,19 @@ package stdutil
 
 import (
 	"bytes"
-	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"reflect"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gbrlsnchs/jwt/v3"
 	"github.com/gorilla/mux"
-	"github.com/narsilworks/livenote"
 )
 
 const (
@@ -24,9 +22,20 @@ const (
 	REQUEST_MODIFIED string = "16052024"
 )
 
+// maxErrorBodySize is the maximum number of bytes of a response body kept in an HTTPError
+const maxErrorBodySize int = 64 << 10
+
 var (
 	reqTimeOut int
-	ct         *http.Transport
+	ct         *http.Transport   // pooled transport created on init
+	dt         http.RoundTripper // default transport of requests
+	dtMu       sync.RWMutex
+)
+
+// Errors
+var (
+	ErrRequestCanceled = errors.New(`request canceled`)          // The context of the request was canceled
+	ErrRequestDeadline = errors.New(`request deadline exceeded`) // The deadline of the context of the request was exceeded
 )
 
 type (
@@ -42,17 +51,46 @@ type (
 	// ResultData - a result structure and a JSON raw message
 	ResultData struct {
 		Result
-		Data json.RawMessage `json:"data"`
+		Data      json.RawMessage `json:"data"`
+		Attempts  int             `json:"-"` // Number of attempts made to get the result
+		FromCache bool            `json:"-"` // The result was served from the cache, including after a 304 Not Modified response
+		Problem   *ProblemDetails `json:"-"` // Problem document (RFC 7807) of an error response
 	}
 	// RequestParam for <REST verb>Api request functions
 	RequestParam struct {
-		TimeOut    int               // Request time out
-		Compressed bool              // Compressed
-		Headers    map[string]string // Headers for the request
-		Mutex      *sync.RWMutex     // Mutex lock for header modification
+		TimeOut         int                 // Request time out
+		Compressed      bool                // Compressed
+		Headers         map[string]string   // Headers for the request
+		HeaderValues    map[string][]string // Multi-value headers for the request. They take precedence over Headers
+		Mutex           *sync.RWMutex       // Mutex lock for header modification
+		Retry           *RetryPolicy        // Retry policy for failed requests
+		Client          *http.Client        // HTTP client to send the request with
+		Transport       http.RoundTripper   // Transport to send the request with. It overrides the transport of the client
+		Interceptors    []Interceptor       // Interceptors of the request, run after the globally registered interceptors
+		CloseConnection bool                // Close the connection after the request instead of reusing it
+		CompressLevel   int                 // Gzip compression level of the request body
+		CompressMinSize *int                // Size of the request body in bytes below which it is not compressed. Default: 1 KB
+		Breaker         *CircuitBreaker     // Circuit breaker of the hosts of the request
+		Limiter         *RateLimiter        // Rate limiter of the request
+		Cache           CacheStore          // Cache of the responses of GET requests
+		attempts        int                 // Number of attempts made
+		cacheHit        bool                // The response was served from the cache
+		sharedCache     bool                // The cache is the shared default store
 	}
 	// RequestOption for <REST verb>Api request functions
 	RequestOption func(opt *RequestParam) error
+	// decodedBody is a decompressing reader of a response body
+	decodedBody struct {
+		io.Reader
+		closers []io.Closer
+	}
+	// HTTPError is returned by ExecuteApi when the server responds with a non-2xx status code
+	HTTPError struct {
+		StatusCode int         // HTTP status code of the response
+		Status     string      // Status text of the response
+		Header     http.Header // Headers of the response
+		Body       []byte      // Body of the response, truncated to 64 KB
+	}
 )
 
 func init() {
@@ -61,6 +99,7 @@ func init() {
 	ct.MaxIdleConns = 100
 	ct.MaxConnsPerHost = 100
 	ct.MaxIdleConnsPerHost = 100
+	dt = ct
 }
 
 // SetRequestTimeOut sets the new timeout value
@@ -68,24 +107,73 @@ func SetRequestTimeout(timeOut int) {
 	reqTimeOut = timeOut
 }
 
+// SetDefaultTransport sets the transport used by requests that do not set a client or a transport.
+// Setting it to nil restores the pooled transport of the package.
+func SetDefaultTransport(rt http.RoundTripper) {
+	dtMu.Lock()
+	defer dtMu.Unlock()
+	if rt == nil {
+		rt = ct
+	}
+	dt = rt
+}
+
+// DefaultTransport returns the transport used by requests that do not set a client or a transport
+func DefaultTransport() http.RoundTripper {
+	dtMu.RLock()
+	defer dtMu.RUnlock()
+	return dt
+}
+
 // ExecuteJsonApi wraps http operation that change or read data and returns a custom result
-func ExecuteJsonApi(method string, endPoint string, payload []byte, compressed bool, header map[string]string, timeOut int, rw *sync.RWMutex) (rd ResultData) {
-	rd = ResultData{
-		Result: InitResult(),
+//
+// Request options, such as Retry, can be added to alter the request further.
+func ExecuteJsonApi(method string, endPoint string, payload []byte, compressed bool, header map[string]string, timeOut int, rw *sync.RWMutex, opts ...RequestOption) (rd ResultData) {
+	return ExecuteJsonApiCtx(context.Background(), method, endPoint, payload, compressed, header, timeOut, rw, opts...)
+}
+
+// ExecuteJsonApiCtx wraps http operation that change or read data with a context and returns a custom result
+//
+// If the context is canceled or its deadline is exceeded, the result will contain
+// an error message starting with ErrRequestCanceled or ErrRequestDeadline.
+func ExecuteJsonApiCtx(ctx context.Context, method string, endPoint string, payload []byte, compressed bool, header map[string]string, timeOut int, rw *sync.RWMutex, opts ...RequestOption) (rd ResultData) {
+	rp := RequestParam{
+		TimeOut:    timeOut,
+		Compressed: compressed,
+		Headers:    header,
+		Mutex:      rw,
 	}
-	if header == nil {
-		header = make(map[string]string)
+	if err := rp.apply(opts); err != nil {
+		return resultData(newResult("executejsonapi"), nil, err, &rp)
 	}
-	if rw == nil {
-		rw = &sync.RWMutex{}
+	return executeJsonApi(ctx, method, endPoint, payload, &rp)
+}
+
+// executeJsonApi executes the request described by the request parameters and returns a custom result
+func executeJsonApi(ctx context.Context, method string, endPoint string, payload []byte, rp *RequestParam) (rd ResultData) {
+	data, err := executeApi(ctx, method, endPoint, payload, rp)
+	return resultData(newResult("executejsonapi"), data, err, rp)
+}
+
+// resultData converts the response body or the error of a request into a custom result.
+// The result passed carries the operation of the request.
+func resultData(res Result, data []byte, err error, rp *RequestParam) (rd ResultData) {
+	rd = ResultData{
+		Result: res,
 	}
-	SafeMapWrite(&header, "Content-Type", "application/json", rw)
-	data, err := ExecuteApi(method, endPoint, payload, compressed, header, timeOut)
+	rd.Attempts = rp.attempts
+	rd.FromCache = rp.cacheHit
 	if err != nil {
+		var herr *HTTPError
+		if errors.As(err, &herr) {
+			rd.assignHTTPError(herr)
+			return
+		}
 		rd.Result.AddErr(err)
 		return
 	}
 	if len(data) == 0 {
+		rd.Return(OK)
 		return
 	}
 
@@ -99,130 +187,310 @@ func ExecuteJsonApi(method string, endPoint string, payload []byte, compressed b
 	}
 
 	// Assign temp to result
+	rd.assign(&trd)
+	return
+}
+
+// assignHTTPError maps an HTTP error onto the result data.
+// If the server responded with a result structure, its messages are used and
+// its status is kept, as WriteResult sends statuses such as INVALID as HTTP errors.
+// Results without a status, or with an OK status, become EXCEPTION results.
+// Problem documents (RFC 7807) are recognised by their content type.
+func (rd *ResultData) assignHTTPError(herr *HTTPError) {
+	if isProblem(herr.Header.Get("Content-Type")) {
+		p := ProblemDetails{}
+		if err := json.Unmarshal(herr.Body, &p); err == nil {
+			rd.Problem = &p
+			rd.assignProblem(&p)
+			return
+		}
+	}
+	trd := ResultData{}
+	if err := json.Unmarshal(herr.Body, &trd); err != nil || (trd.Status == "" && len(trd.Messages) == 0) {
+		rd.Result.AddErr(herr)
+		return
+	}
+	rd.assign(&trd)
+	if trd.Status == "" || trd.Status == string(OK) {
+		rd.Return(EXCEPTION)
+	}
+	if rd.Status == string(EXCEPTION) && !rd.hasErrorNotes() {
+		rd.Result.AddErr(herr)
+	}
+}
+
+// assign copies the data, status, paging information and messages of an unmarshalled result data
+func (rd *ResultData) assign(trd *ResultData) {
 	rd.Data = trd.Data
+	rd.TaskID = trd.TaskID
+	rd.WorkerID = trd.WorkerID
+	rd.FocusControl = trd.FocusControl
+	rd.Page = trd.Page
+	rd.PageCount = trd.PageCount
+	rd.PageSize = trd.PageSize
+	rd.Tag = trd.Tag
+	rd.Entries = append(rd.Entries, trd.Entries...)
 	rd.Return(Status(trd.Status))
 	for _, m := range trd.Messages {
 		if m == "" {
 			continue
 		}
-		msgType := m[0:3]
-		msg := m[3:]
-		if strings.HasPrefix(msg, ":") {
-			msg = msg[2:]
-		}
-		if strings.HasPrefix(msg, "[") {
-			if endBr := strings.Index(msg, "]"); endBr != -1 {
-				rd.ln.Prefix = msg[1:endBr]
-				msg = msg[endBr+3:]
-			}
-		}
-		switch msgType {
-		case string(livenote.Warn):
-			rd.Result.AddWarning(msg)
-		case string(livenote.Error):
-			rd.Result.AddError(msg)
-		case string(livenote.Fatal):
-			rd.Result.AddError(msg)
-		case string(livenote.Success):
-			rd.Result.ln.AddSuccess(msg)
-		case string(livenote.App):
-			rd.Result.ln.AddAppMsg(msg)
-		}
+		rd.ln.Append(ParseMessage(m))
 	}
+	rd.updateMessage()
+}
 
-	return
+// Error returns the status code, status text and the start of the response body
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("http error %d %s", e.StatusCode, e.Status)
+	if body := strings.TrimSpace(string(e.Body)); body != "" {
+		msg += ": " + StripTrailing(body, 512)
+	}
+	return msg
 }
 
 // ExecuteApi wraps http operation that change or read data and returns a byte array
 //
+// Any 2xx status code is treated as success. Other status codes return an *HTTPError
+// carrying the status, headers and the (truncated) body of the response.
+//
 // On headers:
-//   - Content-Type: If this header is not set, it defaults to "application/json"//
-//   - Content-Encoding: If compressed is true, it is set to "gzip"
-func ExecuteApi(method string, endPoint string, payload []byte, compressed bool, header map[string]string, timeOut int) ([]byte, error) {
-	nr, err := http.NewRequest(method, endPoint, bytes.NewBuffer(payload))
+//   - Headers set by the caller take precedence over the default headers, and keep their casing
+//   - Content-Type: If this header is not set, it defaults to "application/json"
+//   - Content-Encoding: If compressed is true, the body of POST, PUT and PATCH requests is
+//     gzipped and this header is set to "gzip" (see the Compression option)
+//   - Accept-Encoding: If compressed is true, it is set to the registered content decoders
+func ExecuteApi(method string, endPoint string, payload []byte, compressed bool, header map[string]string, timeOut int, opts ...RequestOption) ([]byte, error) {
+	return ExecuteApiCtx(context.Background(), method, endPoint, payload, compressed, header, timeOut, opts...)
+}
+
+// ExecuteApiCtx wraps http operation that change or read data with a context and returns a byte array
+//
+// If the context has a deadline, it takes precedence over the timeOut parameter.
+// Cancellation of the context returns an error wrapping ErrRequestCanceled or ErrRequestDeadline.
+func ExecuteApiCtx(ctx context.Context, method string, endPoint string, payload []byte, compressed bool, header map[string]string, timeOut int, opts ...RequestOption) ([]byte, error) {
+	rp := RequestParam{
+		TimeOut:    timeOut,
+		Compressed: compressed,
+		Headers:    header,
+	}
+	if err := rp.apply(opts); err != nil {
+		return nil, err
+	}
+	return executeApi(ctx, method, endPoint, payload, &rp)
+}
+
+// executeApi executes the request described by the request parameters,
+// retrying it if a retry policy was set.
+func executeApi(ctx context.Context, method string, endPoint string, payload []byte, rp *RequestParam) ([]byte, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var data []byte
+	err := retryRequest(ctx, method, rp, func() (err error) {
+		data, err = sendRequest(ctx, method, endPoint, payload, rp)
+		return err
+	})
+	return data, err
+}
+
+// retryRequest runs the attempt until it succeeds or the retry policy gives up
+func retryRequest(ctx context.Context, method string, rp *RequestParam, attempt func() error) error {
+	rp.attempts = 0
+	for {
+		rp.attempts++
+		err := attempt()
+		if err == nil || rp.Retry == nil {
+			return err
+		}
+		wait, retry := rp.Retry.next(method, rp.attempts, err)
+		if !retry {
+			return err
+		}
+		if serr := sleepCtx(ctx, wait); serr != nil {
+			return contextError(ctx, serr)
+		}
+	}
+}
+
+// sendRequest sends a single request and reads the whole response
+func sendRequest(ctx context.Context, method string, endPoint string, payload []byte, rp *RequestParam) ([]byte, error) {
+	if rp.Cache != nil && strings.EqualFold(method, "GET") {
+		return sendCachedRequest(ctx, endPoint, rp)
+	}
+	resp, err := doRequest(ctx, method, endPoint, bytes.NewReader(payload), rp)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := readResponseBody(resp)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	return data, nil
+}
+
+// doRequest sends a single request and returns the response if the status code is 2xx.
+// The caller must close the body of the response.
+func doRequest(ctx context.Context, method string, endPoint string, body io.Reader, rp *RequestParam) (*http.Response, error) {
+	u, err := url.Parse(endPoint)
 	if err != nil {
 		return nil, err
 	}
-	nr.Close = true
+	// An open circuit fails before any rate limit quota is used
+	done := func(*http.Response, error) {}
+	if rp.Breaker != nil {
+		if done, err = rp.Breaker.allow(u.Host); err != nil {
+			return nil, err
+		}
+	}
+	// Wait before the body is built, as a streamed compressed body starts encoding right away
+	if rp.Limiter != nil {
+		if err = rp.Limiter.Wait(ctx, u.Host); err != nil {
+			done(nil, errRequestNotSent)
+			return nil, err
+		}
+	}
+	encoded := false
+	if compressesBody(method, body, rp) {
+		if body, encoded, err = compressBody(body, rp); err != nil {
+			done(nil, errRequestNotSent)
+			return nil, err
+		}
+	}
+	nr, err := http.NewRequestWithContext(ctx, method, endPoint, body)
+	if err != nil {
+		if encoded {
+			closeBody(body, err)
+		}
+		done(nil, errRequestNotSent)
+		return nil, err
+	}
+	nr.Close = rp.CloseConnection
 	nr.Header.Set(
 		"User-Agent",
 		fmt.Sprintf("com.github.eaglebush.stdutil.request/%s-%s",
 			REQUEST_VERSION, REQUEST_MODIFIED))
-	nr.Header.Set("Connection", "keep-alive")
 	nr.Header.Set("Accept", "*/*")
-	if ct := nr.Header.Get("Content-Type"); ct == "" {
-		nr.Header.Set("Content-Type", "application/json")
+	nr.Header.Set("Content-Type", "application/json")
+	if rp.Compressed {
+		nr.Header.Set("Accept-Encoding", acceptEncoding())
+	}
+	rp.mergeHeaders(nr)
+	if encoded {
+		nr.Header.Set("Content-Encoding", "gzip")
+	}
+	cli := rp.client(ctx)
+	resp, err := cli.Do(nr)
+	if err != nil {
+		err = contextError(ctx, err)
+		done(nil, err)
+		return nil, err
+	}
+	done(resp, nil)
+	if rp.Limiter != nil {
+		rp.Limiter.observe(nr.URL.Host, resp.Header)
 	}
-	if compressed {
-		nr.Header.Set("Accept-Encoding", "gzip, deflate, br")
-		switch strings.ToUpper(nr.Method) {
-		case "POST", "PUT", "PATCH":
-			nr.Header.Add("Content-Encoding", "gzip")
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		herr := &HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     http.StatusText(resp.StatusCode),
+			Header:     resp.Header,
 		}
+		if rc, err := decodeBody(resp); err == nil {
+			herr.Body, _ = io.ReadAll(io.LimitReader(rc, int64(maxErrorBodySize)))
+			rc.Close()
+		}
+		return nil, herr
 	}
-	for k, v := range header {
-		k = strings.ToLower(k)
-		if k != "cookie" {
-			nr.Header.Set(k, v)
-			continue
+	return resp, nil
+}
+
+// mergeHeaders sets the headers of the request parameter over the default headers of the request.
+// The casing of the header names set by the caller is kept, and cookies are parsed the same way
+// the cookies of a received request are.
+func (rp *RequestParam) mergeHeaders(nr *http.Request) {
+	set := func(name string, values []string) {
+		if strings.EqualFold(name, "Cookie") {
+			for _, v := range values {
+				cr := http.Request{Header: http.Header{"Cookie": {v}}}
+				for _, ck := range cr.Cookies() {
+					nr.AddCookie(ck)
+				}
+			}
+			return
 		}
-		for _, nvs := range strings.Split(v, `;`) {
-			if nv := strings.Split(nvs, `=`); len(nv) > 1 {
-				nr.AddCookie(&http.Cookie{
-					Name:  strings.TrimSpace(nv[0]),
-					Value: strings.TrimSpace(nv[1]),
-				})
+		for k := range nr.Header {
+			if strings.EqualFold(k, name) {
+				delete(nr.Header, k)
 			}
 		}
+		nr.Header[name] = append([]string(nil), values...)
 	}
-	if timeOut == 0 {
-		timeOut = 30
+	if rp.Mutex != nil {
+		rp.Mutex.RLock()
 	}
-	cli := http.Client{
-		Timeout:   time.Second * time.Duration(timeOut),
-		Transport: ct,
+	for k, v := range rp.Headers {
+		set(k, []string{v})
 	}
-	resp, err := cli.Do(nr)
-	if err != nil {
-		return nil, err
+	if rp.Mutex != nil {
+		rp.Mutex.RUnlock()
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, err
+	for k, v := range rp.HeaderValues {
+		set(k, v)
 	}
-	var data []byte
+}
 
-	if !resp.Uncompressed {
-		ce := resp.Header.Get("Content-Encoding")
-		if ce == "gzip" {
-			raw, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return nil, err
-			}
-			gzr, err := gzip.NewReader(bytes.NewBuffer(raw))
-			if err != nil {
-				return nil, err
-			}
-			defer gzr.Close()
-			for {
-				uz := make([]byte, 1024)
-				cnt, err := gzr.Read(uz)
-				if err != nil {
-					if !errors.Is(err, io.ErrUnexpectedEOF) {
-						return nil, err
-					}
-					break
-				}
-				if cnt == 0 {
-					break
-				}
-				data = append(data, uz[0:cnt]...)
-			}
-			return data, nil
+// client returns the HTTP client to send the request with
+func (rp *RequestParam) client(ctx context.Context) http.Client {
+	cli := http.Client{}
+	if rp.Client != nil {
+		cli = *rp.Client
+	}
+	if rp.Transport != nil {
+		cli.Transport = rp.Transport
+	}
+	if cli.Transport == nil {
+		cli.Transport = DefaultTransport()
+	}
+	cli.Transport = chainInterceptors(cli.Transport, rp.Interceptors)
+	if cli.Timeout == 0 {
+		timeOut := rp.TimeOut
+		if timeOut == 0 {
+			timeOut = 30
 		}
+		cli.Timeout = time.Second * time.Duration(timeOut)
+	}
+	// A negative timeout or a context deadline lifts the time limit of the client
+	if _, ok := ctx.Deadline(); ok || cli.Timeout < 0 {
+		cli.Timeout = 0
+	}
+	return cli
+}
+
+// contextError wraps an error with ErrRequestCanceled or ErrRequestDeadline if the context is done
+func contextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch cerr := ctx.Err(); {
+	case errors.Is(cerr, context.Canceled):
+		return fmt.Errorf("%w: %w", ErrRequestCanceled, cerr)
+	case errors.Is(cerr, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrRequestDeadline, cerr)
 	}
+	return err
+}
 
-	data, err = io.ReadAll(resp.Body)
+// readResponseBody reads the whole response body, decompressing it if needed
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	rc, err := decodeBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
 	if err != nil {
 		if !errors.Is(err, io.ErrUnexpectedEOF) {
 			return nil, err
@@ -231,6 +499,52 @@ func ExecuteApi(method string, endPoint string, payload []byte, compressed bool,
 	return data, nil
 }
 
+// decodeBody returns a reader that decodes the response body on the fly
+// according to its content encoding
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	ce := resp.Header.Get("Content-Encoding")
+	if resp.Uncompressed || ce == "" {
+		return resp.Body, nil
+	}
+	db := &decodedBody{
+		Reader:  resp.Body,
+		closers: []io.Closer{resp.Body},
+	}
+	// Encodings are listed in the order they were applied
+	encs := strings.Split(ce, ",")
+	for i := len(encs) - 1; i >= 0; i-- {
+		enc := strings.ToLower(strings.TrimSpace(encs[i]))
+		if enc == "" || enc == "identity" {
+			continue
+		}
+		dec, ok := contentDecoder(enc)
+		if !ok {
+			return nil, fmt.Errorf("unsupported content encoding %q", enc)
+		}
+		rc, err := dec(db.Reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return resp.Body, nil // empty body
+			}
+			return nil, err
+		}
+		db.Reader = rc
+		db.closers = append([]io.Closer{rc}, db.closers...)
+	}
+	return db, nil
+}
+
+// Close closes the decoder and the underlying body
+func (db *decodedBody) Close() error {
+	var err error
+	for _, c := range db.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
 // GetJson wraps http.Get and gets a raw json message data
 func GetJson(endpoint string, headers map[string]string, rw *sync.RWMutex) ResultData {
 	return ExecuteJsonApi("GET", endpoint, nil, false, headers, reqTimeOut, rw)
@@ -317,100 +631,15 @@ func ParseRouteVars(r *http.Request) (Command []string, Key string) {
 	return cmd, key
 }
 
-// SignJwt builds a JWT token using HMAC256 algorithm
-func SignJwt(claims *map[string]interface{}, secretKey string) string {
-	clm := *claims
-	var (
-		usr, dom, app, dev string
-		iss, sub, jti, tnt string
-		exp, nbf, iat      int64
-	)
-
-	aud := jwt.Audience{}
-	var ifc interface{}
-	if ifc = clm["iss"]; ifc != nil {
-		iss = ifc.(string)
-	}
-	if ifc = clm["sub"]; ifc != nil {
-		sub = ifc.(string)
-	}
-	if ifc = clm["aud"]; ifc != nil {
-		t := reflect.TypeOf(ifc)
-
-		// check if this is a slice
-		if t.Kind() == reflect.Slice {
-			// check if what type of slice are the elements
-			if t.Elem().Kind() == reflect.String {
-				aud = ifc.([]string)
-			}
-		}
-
-		// check if this is a string
-		if t.Kind() == reflect.String {
-			aud = jwt.Audience([]string{ifc.(string)})
-		}
-	}
-	if ifc = clm["exp"]; ifc != nil {
-		exp = ifc.(int64)
-	}
-	if ifc = clm["nbf"]; ifc != nil {
-		nbf = ifc.(int64)
-	}
-	if ifc = clm["iat"]; ifc != nil {
-		iat = ifc.(int64)
-	}
-	if ifc = clm["usr"]; ifc != nil {
-		usr = ifc.(string)
-	}
-	if ifc = clm["dom"]; ifc != nil {
-		dom = ifc.(string)
-	}
-	if ifc = clm["app"]; ifc != nil {
-		app = ifc.(string)
-	}
-	if ifc = clm["dev"]; ifc != nil {
-		dev = ifc.(string)
-	}
-	if ifc = clm["jti"]; ifc != nil {
-		jti = ifc.(string)
-	}
-	if ifc = clm["tnt"]; ifc != nil {
-		tnt = ifc.(string)
-	}
-
-	unixt := func(unixts int64) *jwt.Time {
-		epoch := time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
-		tt := time.Unix(unixts, 0)
-		if tt.Before(epoch) {
-			tt = epoch
-		}
-		return &jwt.Time{Time: tt}
-	}
-
-	pl := CustomPayload{
-		Payload: jwt.Payload{
-			Issuer:         iss,
-			Subject:        sub,
-			Audience:       aud,
-			ExpirationTime: unixt(exp),
-			NotBefore:      unixt(nbf),
-			IssuedAt:       unixt(iat),
-			JWTID:          jti,
-		},
-		UserName:      usr,
-		Domain:        dom,
-		ApplicationID: app,
-		DeviceID:      dev,
-		TenantID:      tnt,
-	}
-
-	HMAC := jwt.NewHS256([]byte(secretKey))
-	token, err := jwt.Sign(pl, HMAC)
-	if err != nil {
-		return ""
+// SignJwt builds a JWT token using HMAC256 algorithm.
+//
+// Times (exp, nbf and iat) can be any number of seconds since the epoch, including the float64
+// of decoded JSON, or a time. Claims other than the known ones are signed as they are.
+func SignJwt(claims *map[string]interface{}, secretKey string) (string, error) {
+	if len(secretKey) == 0 {
+		return "", fmt.Errorf(`secret key not set`)
 	}
-
-	return string(token)
+	return SignJwtUsing(claims, HmacKey(secretKey))
 }
 
 // GetRequestVarsOnly get request variables
@@ -466,25 +695,11 @@ func GetRequestVarsOnly(r *http.Request) RequestVars {
 
 // ValidateJwt validates JWT and returns information using HMAC256 algorithm
 func ValidateJwt(r *http.Request, secretKey string, validateTimes bool) (*JWTInfo, error) {
-	var (
-		jwtfromck,
-		jwth string
-		jwtp []string
-	)
-	// Get Authorization header
-	if jwth = r.Header.Get("Authorization"); len(jwth) == 0 {
-		return nil, fmt.Errorf(`authorization header not set`)
-	}
-	if jwtp = strings.Split(jwth, " "); len(jwtp) < 2 {
-		return nil, fmt.Errorf(`invalid authorization header`)
-	}
-	if !strings.EqualFold(strings.TrimSpace(jwtp[0]), "bearer") {
-		return nil, fmt.Errorf(`invalid authorization bearer`)
-	}
-	if jwtfromck = strings.TrimSpace(jwtp[1]); len(jwtfromck) == 0 {
-		return nil, fmt.Errorf(`invalid authorization token`)
+	token, err := bearerToken(r)
+	if err != nil {
+		return nil, err
 	}
-	return ParseJwt(jwtfromck, secretKey, validateTimes)
+	return ParseJwt(token, secretKey, validateTimes)
 }
 
 // ParseJwt validates, parses JWT and returns information using HMAC256 algorithm
@@ -492,52 +707,25 @@ func ParseJwt(token, secretKey string, validateTimes bool) (*JWTInfo, error) {
 	if len(secretKey) == 0 {
 		return nil, fmt.Errorf(`secret key not set`)
 	}
-	var (
-		pl  CustomPayload
-		err error
-	)
-
-	// Parse JWT
-	HMAC := jwt.NewHS256([]byte(secretKey))
-
-	// Validate claims "iat", "exp" and "aud".
-	if validateTimes {
-		now := time.Now()
-		// Use jwt.ValidatePayload to build a jwt.VerifyOption.
-		// Validators are run in the order informed.
-		validator := jwt.ValidatePayload(
-			&pl.Payload,
-			jwt.IssuedAtValidator(now),
-			jwt.ExpirationTimeValidator(now),
-			jwt.NotBeforeValidator(now))
-		_, err = jwt.Verify([]byte(token), HMAC, &pl, validator)
-	} else {
-		_, err = jwt.Verify([]byte(token), HMAC, &pl)
-	}
-	if err != nil {
-		return nil, err
-	}
-	return &JWTInfo{
-		Audience:      pl.Audience,
-		UserName:      pl.UserName,
-		Domain:        pl.Domain,
-		DeviceID:      pl.DeviceID,
-		ApplicationID: pl.ApplicationID,
-		TenantID:      pl.TenantID,
-		Raw:           token,
-		Valid:         true,
-	}, nil
+	return ParseJwtUsing(token, HmacKey(secretKey), validateTimes)
 }
 
 // GetRequestVars requests variables and return JWT validation result
 func GetRequestVars(r *http.Request, secretKey string, validateTimes bool) (RequestVars, error) {
+	return getRequestVars(r, func() (*JWTInfo, error) {
+		return ValidateJwt(r, secretKey, validateTimes)
+	})
+}
+
+// getRequestVars requests variables and validates the JWT of the request with the validate function
+func getRequestVars(r *http.Request, validate func() (*JWTInfo, error)) (RequestVars, error) {
 	rv := GetRequestVarsOnly(r)
 	rv.Token = nil
 	// silently ignore OPTION methid
 	if strings.EqualFold(r.Method, "OPTION") {
 		return rv, nil
 	}
-	ji, err := ValidateJwt(r, secretKey, validateTimes)
+	ji, err := validate()
 	if err != nil {
 		return rv, err
 	}
@@ -572,6 +760,19 @@ func getJsonConverted[T any](rslt *ResultData) ResultAny[T] {
 	}
 }
 
+// apply runs the request options against the request parameter
+func (rp *RequestParam) apply(opts []RequestOption) error {
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if err := o(rp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // TimeOut sets the request timeout as an option
 //
 // This is used with <REST verb>Api functions
@@ -603,8 +804,75 @@ func Headers(hdr map[string]string, mut *sync.RWMutex) RequestOption {
 	}
 }
 
+// HeaderValues adds multi-value request headers as an option.
+// The headers are merged with those of earlier HeaderValues and Header options.
+//
+// This is used with <REST verb>Api functions
+func HeaderValues(hdr map[string][]string) RequestOption {
+	return func(rp *RequestParam) error {
+		if rp.HeaderValues == nil {
+			rp.HeaderValues = make(map[string][]string, len(hdr))
+		}
+		for k, v := range hdr {
+			rp.HeaderValues[k] = append(rp.HeaderValues[k], v...)
+		}
+		return nil
+	}
+}
+
+// Header sets a request header with one or more values as an option.
+// It replaces the values set for the header by earlier options.
+//
+// This is used with <REST verb>Api functions
+func Header(name string, values ...string) RequestOption {
+	return func(rp *RequestParam) error {
+		if rp.HeaderValues == nil {
+			rp.HeaderValues = make(map[string][]string)
+		}
+		rp.HeaderValues[name] = values
+		return nil
+	}
+}
+
+// KeepAlive sets whether the connection is kept open for reuse after the request, as an option.
+// Connections are reused by default.
+//
+// This is used with <REST verb>Api functions
+func KeepAlive(keepAlive bool) RequestOption {
+	return func(rp *RequestParam) error {
+		rp.CloseConnection = !keepAlive
+		return nil
+	}
+}
+
+// Client sets the HTTP client to send the request with as an option.
+// The client's transport is used unless a transport is also set.
+//
+// This is used with <REST verb>Api functions
+func Client(cli *http.Client) RequestOption {
+	return func(rp *RequestParam) error {
+		rp.Client = cli
+		return nil
+	}
+}
+
+// Transport sets the transport to send the request with as an option
+//
+// This is used with <REST verb>Api functions
+func Transport(rt http.RoundTripper) RequestOption {
+	return func(rp *RequestParam) error {
+		rp.Transport = rt
+		return nil
+	}
+}
+
 // CreateApi posts data on an API endpoint and converts the returned data into a resulting type
 func CreateApi[T any, U any](url string, pl U, opts ...RequestOption) ResultAny[T] {
+	return CreateApiCtx[T](context.Background(), url, pl, opts...)
+}
+
+// CreateApiCtx posts data on an API endpoint with a context and converts the returned data into a resulting type
+func CreateApiCtx[T any, U any](ctx context.Context, url string, pl U, opts ...RequestOption) ResultAny[T] {
 	b, err := json.Marshal(pl)
 	if err != nil {
 		return ResultAny[T]{
@@ -619,33 +887,51 @@ func CreateApi[T any, U any](url string, pl U, opts ...RequestOption) ResultAny[
 	rp := RequestParam{
 		Compressed: false,
 	}
-	for _, o := range opts {
-		if o == nil {
-			continue
+	if err := rp.apply(opts); err != nil {
+		return ResultAny[T]{
+			Result: InitResult(
+				NameValue[string]{
+					Name:  "message",
+					Value: err.Error(),
+				},
+			),
 		}
-		o(&rp)
 	}
-	rd := ExecuteJsonApi("POST", url, b, rp.Compressed, rp.Headers, rp.TimeOut, rp.Mutex)
+	rd := executeJsonApi(ctx, "POST", url, b, &rp)
 	return getJsonConverted[T](&rd)
 }
 
 // ReadApi retrieves data on an API endpoint and converts the returned data into a resulting type
 func ReadApi[T any](url string, opts ...RequestOption) ResultAny[T] {
+	return ReadApiCtx[T](context.Background(), url, opts...)
+}
+
+// ReadApiCtx retrieves data on an API endpoint with a context and converts the returned data into a resulting type
+func ReadApiCtx[T any](ctx context.Context, url string, opts ...RequestOption) ResultAny[T] {
 	rp := RequestParam{
 		Compressed: true,
 	}
-	for _, o := range opts {
-		if o == nil {
-			continue
+	if err := rp.apply(opts); err != nil {
+		return ResultAny[T]{
+			Result: InitResult(
+				NameValue[string]{
+					Name:  "message",
+					Value: err.Error(),
+				},
+			),
 		}
-		o(&rp)
 	}
-	rd := ExecuteJsonApi("GET", url, nil, rp.Compressed, rp.Headers, rp.TimeOut, rp.Mutex)
+	rd := executeJsonApi(ctx, "GET", url, nil, &rp)
 	return getJsonConverted[T](&rd)
 }
 
 // UpdateApi updates data on an API endpoint and converts the returned data into a resulting type
 func UpdateApi[T any, U any](url string, pl U, opts ...RequestOption) ResultAny[T] {
+	return UpdateApiCtx[T](context.Background(), url, pl, opts...)
+}
+
+// UpdateApiCtx updates data on an API endpoint with a context and converts the returned data into a resulting type
+func UpdateApiCtx[T any, U any](ctx context.Context, url string, pl U, opts ...RequestOption) ResultAny[T] {
 	b, err := json.Marshal(pl)
 	if err != nil {
 		return ResultAny[T]{
@@ -658,33 +944,51 @@ func UpdateApi[T any, U any](url string, pl U, opts ...RequestOption) ResultAny[
 		}
 	}
 	rp := RequestParam{}
-	for _, o := range opts {
-		if o == nil {
-			continue
+	if err := rp.apply(opts); err != nil {
+		return ResultAny[T]{
+			Result: InitResult(
+				NameValue[string]{
+					Name:  "message",
+					Value: err.Error(),
+				},
+			),
 		}
-		o(&rp)
 	}
-	rd := ExecuteJsonApi("PUT", url, b, rp.Compressed, rp.Headers, rp.TimeOut, rp.Mutex)
+	rd := executeJsonApi(ctx, "PUT", url, b, &rp)
 	return getJsonConverted[T](&rd)
 }
 
 // DeleteApi deletes data on an API endpoint and converts the returned data into a resulting type
 func DeleteApi[T any](url string, opts ...RequestOption) ResultAny[T] {
+	return DeleteApiCtx[T](context.Background(), url, opts...)
+}
+
+// DeleteApiCtx deletes data on an API endpoint with a context and converts the returned data into a resulting type
+func DeleteApiCtx[T any](ctx context.Context, url string, opts ...RequestOption) ResultAny[T] {
 	rp := RequestParam{
 		Compressed: false,
 	}
-	for _, o := range opts {
-		if o == nil {
-			continue
+	if err := rp.apply(opts); err != nil {
+		return ResultAny[T]{
+			Result: InitResult(
+				NameValue[string]{
+					Name:  "message",
+					Value: err.Error(),
+				},
+			),
 		}
-		o(&rp)
 	}
-	rd := ExecuteJsonApi("DELETE", url, nil, rp.Compressed, rp.Headers, rp.TimeOut, rp.Mutex)
+	rd := executeJsonApi(ctx, "DELETE", url, nil, &rp)
 	return getJsonConverted[T](&rd)
 }
 
 // PatchApi patches data on an API endpoint and converts the returned data into a resulting type
 func PatchApi[T any, U any](url string, pl U, opts ...RequestOption) ResultAny[T] {
+	return PatchApiCtx[T](context.Background(), url, pl, opts...)
+}
+
+// PatchApiCtx patches data on an API endpoint with a context and converts the returned data into a resulting type
+func PatchApiCtx[T any, U any](ctx context.Context, url string, pl U, opts ...RequestOption) ResultAny[T] {
 	b, err := json.Marshal(pl)
 	if err != nil {
 		return ResultAny[T]{
@@ -699,12 +1003,16 @@ func PatchApi[T any, U any](url string, pl U, opts ...RequestOption) ResultAny[T
 	rp := RequestParam{
 		Compressed: false,
 	}
-	for _, o := range opts {
-		if o == nil {
-			continue
+	if err := rp.apply(opts); err != nil {
+		return ResultAny[T]{
+			Result: InitResult(
+				NameValue[string]{
+					Name:  "message",
+					Value: err.Error(),
+				},
+			),
 		}
-		o(&rp)
 	}
-	rd := ExecuteJsonApi("PATCH", url, b, rp.Compressed, rp.Headers, rp.TimeOut, rp.Mutex)
+	rd := executeJsonApi(ctx, "PATCH", url, b, &rp)
 	return getJsonConverted[T](&rd)
 }
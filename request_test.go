@@ -1,14 +1,21 @@
 package stdutil
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/narsilworks/livenote"
 )
 
 func TestExecuteAPIPOST(t *testing.T) {
@@ -90,9 +97,9 @@ func TestBuildAccessToken(t *testing.T) {
 		"app": "APPSHUB-AITH",
 	}
 
-	token := SignJwt(&jwtc, "thisisanhmacsecretkey")
-	if token == "" {
-		t.Fail()
+	token, err := SignJwt(&jwtc, "thisisanhmacsecretkey")
+	if err != nil {
+		t.Fatal(err)
 	}
 	fmt.Println(token)
 }
@@ -199,18 +206,290 @@ func TestMessageParsing(t *testing.T) {
 		if m == "" {
 			continue
 		}
-		msgType := m[0:3]
-		prefix := ""
-		msg := m[3:]
-		if strings.HasPrefix(msg, ":") {
-			msg = msg[2:]
+		n := ParseMessage(m)
+		if n.Type != livenote.Error {
+			t.Errorf("unexpected message type %s", n.Type)
+		}
+		t.Logf("Message Type: %s, Prefix: %s, Message: %s", n.Type, n.Prefix, n.Message)
+	}
+}
+
+func TestExecuteApiHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Trace", "abc")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("user not found"))
+	}))
+	defer srv.Close()
+
+	_, err := ExecuteApi("GET", srv.URL, nil, false, nil, 5)
+	var herr *HTTPError
+	if !errors.As(err, &herr) {
+		t.Fatalf("expected *HTTPError, got %v", err)
+	}
+	if herr.StatusCode != http.StatusNotFound || herr.Status != "Not Found" {
+		t.Errorf("unexpected status %d %s", herr.StatusCode, herr.Status)
+	}
+	if herr.Header.Get("X-Trace") != "abc" {
+		t.Errorf("response headers not kept")
+	}
+	if string(herr.Body) != "user not found" {
+		t.Errorf("unexpected body %q", herr.Body)
+	}
+}
+
+func TestExecuteApiCreated(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status":"OK","messages":[],"data":{"id":12}}`))
+	}))
+	defer srv.Close()
+
+	rd := ExecuteJsonApi("POST", srv.URL, []byte(`{}`), false, nil, 5, nil)
+	if !rd.OK() {
+		t.Fatalf("expected OK, got %s %v", rd.Status, rd.Messages)
+	}
+	if string(rd.Data) != `{"id":12}` {
+		t.Errorf("unexpected data %s", rd.Data)
+	}
+}
+
+func TestExecuteJsonApiServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		if r.URL.Path == "/fatal" {
+			w.Write([]byte(`{"status":"EXCEPTION","messages":["FTL: database is down"],"data":null}`))
+			return
+		}
+		w.Write([]byte(`{"status":"OK","messages":["ERR: database is down"],"data":null}`))
+	}))
+	defer srv.Close()
+
+	// Fatal messages count as errors, so no HTTP error message is added
+	for _, path := range []string{"/error", "/fatal"} {
+		rd := ExecuteJsonApi("GET", srv.URL+path, nil, false, nil, 5, nil)
+		if !rd.Error() {
+			t.Fatalf("%s: expected EXCEPTION, got %s", path, rd.Status)
+		}
+		if len(rd.Messages) != 1 || !strings.Contains(rd.Messages[0], "database is down") {
+			t.Errorf("%s: unexpected messages %v", path, rd.Messages)
+		}
+	}
+}
+
+func TestExecuteApiCtx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := ExecuteApiCtx(ctx, "GET", srv.URL, nil, false, nil, 30)
+	if !errors.Is(err, ErrRequestDeadline) {
+		t.Fatalf("expected ErrRequestDeadline, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	res := ReadApiCtx[any](ctx, srv.URL)
+	if !res.Error() {
+		t.Fatalf("expected EXCEPTION, got %s", res.Status)
+	}
+	if len(res.Messages) == 0 || !strings.Contains(res.Messages[0], ErrRequestCanceled.Error()) {
+		t.Errorf("unexpected messages %v", res.Messages)
+	}
+}
+
+type stubTransport struct {
+	calls int
+	body  string
+}
+
+func (st *stubTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	st.calls++
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(st.body)),
+		Request:    r,
+	}, nil
+}
+
+func TestTransportOption(t *testing.T) {
+	st := &stubTransport{body: `{"status":"OK","messages":[],"data":7}`}
+	res := ReadApi[int]("http://stub.invalid/count", Transport(st))
+	if !res.OK() || res.Data != 7 || st.calls != 1 {
+		t.Fatalf("unexpected result %s %v %d", res.Status, res.Messages, res.Data)
+	}
+
+	SetDefaultTransport(st)
+	defer SetDefaultTransport(nil)
+	if res = ReadApi[int]("http://stub.invalid/count"); !res.OK() || st.calls != 2 {
+		t.Errorf("default transport not used")
+	}
+}
+
+func TestClientOption(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"OK","messages":[],"data":"secure"}`))
+	}))
+	defer srv.Close()
+
+	if res := ReadApi[string](srv.URL); res.OK() {
+		t.Errorf("expected certificate error without the test server client")
+	}
+	res := ReadApi[string](srv.URL, Client(srv.Client()))
+	if !res.OK() || res.Data != "secure" {
+		t.Errorf("unexpected result %s %v", res.Status, res.Messages)
+	}
+}
+
+type captureTransport struct {
+	req *http.Request
+}
+
+func (ct *captureTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ct.req = r
+	return &http.Response{
+		StatusCode: http.StatusNoContent,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    r,
+	}, nil
+}
+
+func TestHeaderMerging(t *testing.T) {
+	tr := &captureTransport{}
+	hdr := map[string]string{
+		"content-type":  "text/plain",
+		"x-Custom-CASE": "kept",
+		"Cookie":        `session="a b"; theme=dark`,
+	}
+	res := CreateApi[any]("http://stub.invalid/",
+		"payload",
+		Transport(tr),
+		Headers(hdr, &sync.RWMutex{}),
+		HeaderValues(map[string][]string{"X-Multi": {"one", "two"}}),
+		Header("Accept", "application/json"))
+	if !res.OK() {
+		t.Fatalf("unexpected result %s %v", res.Status, res.Messages)
+	}
+	h := tr.req.Header
+	if v := h["content-type"]; len(v) != 1 || v[0] != "text/plain" || h.Get("Content-Type") != "" {
+		t.Errorf("caller content type did not win: %v", h)
+	}
+	if v := h["x-Custom-CASE"]; len(v) != 1 || v[0] != "kept" {
+		t.Errorf("header casing not kept: %v", h)
+	}
+	if v := h.Values("X-Multi"); len(v) != 2 {
+		t.Errorf("multi-value header not set: %v", v)
+	}
+	if v := h.Get("Accept"); v != "application/json" {
+		t.Errorf("default accept header not replaced: %s", v)
+	}
+	if ck, err := tr.req.Cookie("session"); err != nil || ck.Value != "a b" {
+		t.Errorf("cookie not parsed: %v %v", ck, err)
+	}
+	if _, ok := hdr["Content-Type"]; ok {
+		t.Errorf("caller headers were modified")
+	}
+	if tr.req.Close {
+		t.Errorf("expected the connection to be reused")
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"OK","messages":[],"data":null}`))
+	}))
+	srv.Config.ConnState = func(c net.Conn, cs http.ConnState) {
+		if cs == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		ReadApi[any](srv.URL)
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("expected a single reused connection, got %d", n)
+	}
+	// The idle connection is used once more, then closed
+	for i := 0; i < 3; i++ {
+		ReadApi[any](srv.URL, KeepAlive(false))
+	}
+	if n := atomic.LoadInt32(&conns); n != 3 {
+		t.Errorf("expected a new connection per request, got %d", n)
+	}
+}
+
+func TestExecuteJsonApiMessages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"OK","messages":["INF[users]: 100% loaded","WRN: slow",` +
+			`"ERR[db]: retrying","ERR","plain text",""],"data":null}`))
+	}))
+	defer srv.Close()
+
+	rd := ExecuteJsonApi("GET", srv.URL, nil, false, nil, 5, nil)
+	want := []string{
+		"INF[users]: 100% loaded",
+		"WRN: slow",
+		"ERR[db]: retrying",
+		"INF: ERR", // malformed messages are kept as info messages
+		"INF: plain text",
+	}
+	if strings.Join(rd.Messages, "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected messages %q", rd.Messages)
+	}
+	if rd.MessagePrefix != "" || rd.MessageManager().Prefix != "" {
+		t.Errorf("result prefix was overwritten")
+	}
+}
+
+func TestExecuteJsonApiOperation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"OK"}`))
+	}))
+	defer srv.Close()
+	for name, res := range map[string]Result{
+		"ExecuteJsonApi": ExecuteJsonApi("GET", srv.URL, nil, false, nil, 5, nil).Result,
+		"ReadApi":        ReadApi[any](srv.URL).Result,
+		"invalid option": ExecuteJsonApi("GET", srv.URL, nil, false, nil, 5, nil, Compression(99, 0)).Result,
+	} {
+		if res.Operation != "executejsonapi" || res.EventID() != "executejsonapied" {
+			t.Errorf("%s: unexpected operation %q, event %q", name, res.Operation, res.EventID())
+		}
+	}
+	if rd := PostForm(srv.URL, NameValues{}); rd.Operation != "postform" {
+		t.Errorf("unexpected operation %q", rd.Operation)
+	}
+}
+
+func TestExecuteJsonApiEntries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := InitResult()
+		res.AddErrorCode("duplicate_key", "email", "email is taken")
+		if r.URL.Path == "/problem" {
+			WriteProblem(w, res.ToProblem(http.StatusConflict))
+			return
+		}
+		WriteResult(w, r, &res)
+	}))
+	defer srv.Close()
+	for _, path := range []string{"/result", "/problem"} {
+		rd := ExecuteJsonApi("POST", srv.URL+path, []byte(`{}`), false, nil, 5, nil)
+		if rd.OK() || len(rd.Messages) != 1 {
+			t.Errorf("%s: unexpected result %s %v", path, rd.Status, rd.Messages)
 		}
-		if strings.HasPrefix(msg, "[") {
-			if endBr := strings.Index(msg, "]"); endBr != -1 {
-				prefix = msg[1:endBr]
-				msg = msg[endBr+3:]
-			}
+		if e, ok := rd.EntryByCode("duplicate_key"); !ok || e.Field != "email" || e.Message != "email is taken" {
+			t.Errorf("%s: unexpected entries %+v", path, rd.Entries)
 		}
-		t.Logf("Message Type: %s, Prefix: %s, Message: %s", msgType, prefix, msg)
 	}
 }
@@ -3,19 +3,27 @@ package stdutil
 import (
 	"encoding/json"
 	"errors"
+	"time"
 )
 
 type (
 	// JWTInfo contains the information about JWT
 	JWTInfo struct {
-		ApplicationID string   // Application ID from the JWT token
-		Audience      []string // Audience intended by the token
-		DeviceID      string   // The device id where the token came from
-		Domain        string   // The application domain that the token is intended for
-		Raw           string   // Raw JWT token
-		TenantID      string   // Tenant ID from the JWT token
-		UserName      string   // User account authenticated and produced the token
-		Valid         bool     // Indicates that the request has a valid JWT token
+		ApplicationID  string         // Application ID from the JWT token
+		Audience       []string       // Audience intended by the token
+		Claims         map[string]any // All claims of the token, as decoded from JSON
+		DeviceID       string         // The device id where the token came from
+		Domain         string         // The application domain that the token is intended for
+		ExpirationTime time.Time      // Time the token expires, zero if not set
+		IssuedAt       time.Time      // Time the token was issued, zero if not set
+		Issuer         string         // Issuer of the token
+		JWTID          string         // Unique id of the token
+		NotBefore      time.Time      // Time before which the token is not accepted, zero if not set
+		Raw            string         // Raw JWT token
+		Subject        string         // Subject of the token
+		TenantID       string         // Tenant ID from the JWT token
+		UserName       string         // User account authenticated and produced the token
+		Valid          bool           // Indicates that the request has a valid JWT token
 	}
 
 	// RequestVars - contains necessary request variables
@@ -21,19 +21,39 @@ const (
 	NO        Status = `NO`
 )
 
+// Severity of a structured message
+type Severity string
+
+// Severity items
+const (
+	SeverityInfo    Severity = `info`
+	SeverityWarning Severity = `warning`
+	SeverityError   Severity = `error`
+)
+
+// MessageEntry is a structured message of a result, which clients can tell apart by code
+type MessageEntry struct {
+	Code     string         `json:"code,omitempty"`    // Code of the message, such as "duplicate_key" or "not_found"
+	Field    string         `json:"field,omitempty"`   // Field or path the message is about
+	Severity Severity       `json:"severity"`          // Severity of the message
+	Message  string         `json:"message"`           // Text of the message
+	Details  map[string]any `json:"details,omitempty"` // Arbitrary details of the message
+}
+
 // Result - standard result structure
 type Result struct {
-	Messages      []string     `json:"messages"`                // Accumulated messages as a result from Add methods. Do not append messages using append()
-	Status        string       `json:"status"`                  // OK, ERROR, VALID or any status
-	Operation     string       `json:"operation,omitempty"`     // Name of the operation / function that returned the result
-	TaskID        *string      `json:"task_id,omitempty"`       // ID of the task and of the result
-	WorkerID      *string      `json:"worker_id,omitempty"`     // ID of the worker that processed the data
-	FocusControl  *string      `json:"focus_control,omitempty"` // Control to focus when error was activated
-	Page          *int         `json:"page,omitempty"`          // Current Page
-	PageCount     *int         `json:"page_count,omitempty"`    // Page Count
-	PageSize      *int         `json:"page_size,omitempty"`     // Page Size
-	Tag           *interface{} `json:"tag,omitempty"`           // Miscellaneous result
-	MessagePrefix string       `json:"prefix,omitempty"`        // Prefix of the message to return
+	Messages      []string       `json:"messages"`                // Accumulated messages as a result from Add methods. Do not append messages using append()
+	Status        string         `json:"status"`                  // OK, ERROR, VALID or any status
+	Operation     string         `json:"operation,omitempty"`     // Name of the operation / function that returned the result
+	TaskID        *string        `json:"task_id,omitempty"`       // ID of the task and of the result
+	WorkerID      *string        `json:"worker_id,omitempty"`     // ID of the worker that processed the data
+	FocusControl  *string        `json:"focus_control,omitempty"` // Control to focus when error was activated
+	Page          *int           `json:"page,omitempty"`          // Current Page
+	PageCount     *int           `json:"page_count,omitempty"`    // Page Count
+	PageSize      *int           `json:"page_size,omitempty"`     // Page Size
+	Tag           *interface{}   `json:"tag,omitempty"`           // Miscellaneous result
+	MessagePrefix string         `json:"prefix,omitempty"`        // Prefix of the message to return
+	Entries       []MessageEntry `json:"entries,omitempty"`       // Structured messages with codes, alongside the messages
 
 	ln        livenote.LiveNote // Internal note
 	eventVerb string            // event verb related to the name of the operation
@@ -83,20 +103,42 @@ func InitResult(args ...NameValue[string]) Result {
 	}
 
 	// Auto-detect function that called this function
-	if pc, _, _, ok := runtime.Caller(1); ok {
-		if details := runtime.FuncForPC(pc); details != nil {
-			nm := details.Name()
-			if pos := strings.LastIndex(nm, `.`); pos != -1 {
-				nm = nm[pos+1:]
-			}
-			res.Operation = strings.ToLower(nm)
-			res.eventVerb = res.Operation
-		}
-	}
+	res.setOperation(callerName(2))
 
 	return res
 }
 
+// newResult initializes a result of an operation, for results not named after the function creating them
+func newResult(op string) Result {
+	res := InitResult()
+	res.setOperation(op)
+	return res
+}
+
+// callerName returns the lowercased name of a function in the call stack,
+// skip being the number of frames above the caller of callerName
+func callerName(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip)
+	if !ok {
+		return ""
+	}
+	details := runtime.FuncForPC(pc)
+	if details == nil {
+		return ""
+	}
+	nm := strings.TrimSuffix(details.Name(), `[...]`) // generic functions
+	if pos := strings.LastIndex(nm, `.`); pos != -1 {
+		nm = nm[pos+1:]
+	}
+	return strings.ToLower(nm)
+}
+
+// setOperation sets the operation of a result and the verb of its event
+func (r *Result) setOperation(op string) {
+	r.Operation = op
+	r.eventVerb = op
+}
+
 // MessageManager returns the internal message manager
 func (r *Result) MessageManager() *livenote.LiveNote {
 	return &r.ln
@@ -108,6 +150,12 @@ func (r *Result) Return(status Status) Result {
 	return *r
 }
 
+// ResultStatus returns the status of a result. It makes *Result, and pointers to the
+// types embedding it, a StatusReporter that can be written by WriteResult.
+func (r *Result) ResultStatus() Status {
+	return Status(r.Status)
+}
+
 // OK returns true if the status is OK.
 func (r *Result) OK() bool {
 	return r.Status == string(OK)
@@ -184,6 +232,7 @@ func (r *Result) AddErrorWithAlt(rs Result, altMsg string, altMsgValues ...any)
 		for _, n := range rs.ln.Notes() {
 			r.ln.Append(n)
 		}
+		r.Entries = append(r.Entries, rs.Entries...)
 		r.updateMessage()
 		return *r
 	}
@@ -205,6 +254,7 @@ func (r *Result) AppendErr(rs Result, err error) Result {
 	for _, n := range rs.ln.Notes() {
 		r.ln.Append(n)
 	}
+	r.Entries = append(r.Entries, rs.Entries...)
 	return r.AddErr(err)
 }
 
@@ -213,6 +263,7 @@ func (r *Result) AppendError(rs Result, fmtMsg string, a ...interface{}) Result
 	for _, n := range rs.ln.Notes() {
 		r.ln.Append(n)
 	}
+	r.Entries = append(r.Entries, rs.Entries...)
 	return r.AddError(fmtMsg, a...)
 }
 
@@ -221,6 +272,7 @@ func (r *Result) AppendInfo(rs Result, fmtMsg string, a ...interface{}) Result {
 	for _, n := range rs.ln.Notes() {
 		r.ln.Append(n)
 	}
+	r.Entries = append(r.Entries, rs.Entries...)
 	return r.AddInfo(fmtMsg, a...)
 }
 
@@ -229,6 +281,7 @@ func (r *Result) AppendWarning(rs Result, fmtMsg string, a ...interface{}) Resul
 	for _, n := range rs.ln.Notes() {
 		r.ln.Append(n)
 	}
+	r.Entries = append(r.Entries, rs.Entries...)
 	return r.AddWarning(fmtMsg, a...)
 }
 
@@ -237,6 +290,7 @@ func (r *Result) Stuff(rs Result) Result {
 	for _, n := range rs.ln.Notes() {
 		r.ln.Append(n)
 	}
+	r.Entries = append(r.Entries, rs.Entries...)
 	r.updateMessage()
 	return *r
 }
@@ -287,6 +341,128 @@ func (r *Result) updateMessage() {
 	}
 }
 
+// ParseMessage parses a message in the format produced by the Messages of a Result,
+// which is "TYP[prefix]: message" or "TYP: message". Messages that are not in this
+// format are kept whole as info messages.
+func ParseMessage(msg string) livenote.LiveNoteInfo {
+	note := livenote.LiveNoteInfo{
+		Type:    livenote.Info,
+		Message: msg,
+	}
+	if len(msg) < 3 {
+		return note
+	}
+	typ := livenote.NoteType(msg[:3])
+	switch typ {
+	case livenote.Info, livenote.Warn, livenote.Error, livenote.Fatal, livenote.Success:
+	default:
+		return note
+	}
+	rest := msg[3:]
+	prefix := ""
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]"+livenote.DelimMsgType)
+		if end == -1 {
+			return note
+		}
+		prefix = rest[1:end]
+		rest = rest[end+1:]
+	}
+	if !strings.HasPrefix(rest, livenote.DelimMsgType) {
+		return note
+	}
+	return livenote.LiveNoteInfo{
+		Type:    typ,
+		Prefix:  prefix,
+		Message: rest[len(livenote.DelimMsgType):],
+	}
+}
+
+// hasErrorNotes checks if the result has error or fatal messages.
+// Fatal messages are errors that stopped the work, so they count as errors.
+func (r *Result) hasErrorNotes() bool {
+	for _, n := range r.ln.Notes() {
+		if n.Type == livenote.Error || n.Type == livenote.Fatal {
+			return true
+		}
+	}
+	return false
+}
+
+// AddErrorCode adds a formatted error message with a code and the field it is about, and returns itself
+func (r *Result) AddErrorCode(code string, field string, fmtMsg string, a ...interface{}) Result {
+	return r.AddEntry(MessageEntry{
+		Code:     code,
+		Field:    field,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf(fmtMsg, a...),
+	})
+}
+
+// AddWarningCode adds a formatted warning message with a code and the field it is about, and returns itself
+func (r *Result) AddWarningCode(code string, field string, fmtMsg string, a ...interface{}) Result {
+	return r.AddEntry(MessageEntry{
+		Code:     code,
+		Field:    field,
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf(fmtMsg, a...),
+	})
+}
+
+// AddInfoCode adds a formatted information message with a code and the field it is about, and returns itself
+func (r *Result) AddInfoCode(code string, field string, fmtMsg string, a ...interface{}) Result {
+	return r.AddEntry(MessageEntry{
+		Code:     code,
+		Field:    field,
+		Severity: SeverityInfo,
+		Message:  fmt.Sprintf(fmtMsg, a...),
+	})
+}
+
+// AddEntry adds a structured message and returns itself.
+// The message is also added to the messages with the type of its severity.
+func (r *Result) AddEntry(e MessageEntry) Result {
+	switch e.Severity {
+	case SeverityInfo:
+		r.ln.AddInfo(e.Message)
+	case SeverityWarning:
+		r.ln.AddWarning(e.Message)
+	default:
+		e.Severity = SeverityError
+		r.ln.AddError(e.Message)
+	}
+	r.Entries = append(r.Entries, e)
+	r.updateMessage()
+	return *r
+}
+
+// HasCode checks if the result has a structured message with the code
+func (r *Result) HasCode(code string) bool {
+	_, ok := r.EntryByCode(code)
+	return ok
+}
+
+// EntryByCode returns the first structured message with the code
+func (r *Result) EntryByCode(code string) (MessageEntry, bool) {
+	for _, e := range r.Entries {
+		if e.Code == code {
+			return e, true
+		}
+	}
+	return MessageEntry{}, false
+}
+
+// EntriesByField returns the structured messages about a field
+func (r *Result) EntriesByField(field string) []MessageEntry {
+	es := make([]MessageEntry, 0)
+	for _, e := range r.Entries {
+		if e.Field == field {
+			es = append(es, e)
+		}
+	}
+	return es
+}
+
 // RowsAffectedInfo - a function to simplify adding information for rows affected
 func (r *Result) RowsAffectedInfo(rowsaff int64) {
 	if rowsaff != 0 {
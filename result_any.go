@@ -42,6 +42,24 @@ func (r *ResultAny[T]) AddErr(err error) ResultAny[T] {
 	}
 }
 
+// AddErrorCode adds a formatted error message with a code and the field it is about, and returns itself
+func (r *ResultAny[T]) AddErrorCode(code string, field string, fmtMsg string, a ...interface{}) ResultAny[T] {
+	r.Result.AddErrorCode(code, field, fmtMsg, a...)
+	return ResultAny[T]{
+		Result: r.Result,
+		Data:   r.Data,
+	}
+}
+
+// AddEntry adds a structured message and returns itself
+func (r *ResultAny[T]) AddEntry(e MessageEntry) ResultAny[T] {
+	r.Result.AddEntry(e)
+	return ResultAny[T]{
+		Result: r.Result,
+		Data:   r.Data,
+	}
+}
+
 // Stuff adds or appends the messages of a Result.
 func (r *ResultAny[T]) Stuff(rs Result) ResultAny[T] {
 	r.Result.Stuff(rs)
@@ -2,7 +2,11 @@ package stdutil
 
 import (
 	"errors"
+	"math"
+	"strings"
 	"testing"
+
+	"github.com/narsilworks/livenote"
 )
 
 // func TestResultMessage(t *testing.T) {
@@ -134,3 +138,85 @@ func TestMessageMix(t *testing.T) {
 	}
 
 }
+
+func TestParseMessage(t *testing.T) {
+	for msg, want := range map[string]livenote.LiveNoteInfo{
+		"ERR: failed":             {Type: livenote.Error, Message: "failed"},
+		"WRN[stdutil]: careful":   {Type: livenote.Warn, Prefix: "stdutil", Message: "careful"},
+		"SUC[a]b]: done":          {Type: livenote.Success, Prefix: "a]b", Message: "done"},
+		"FTL: ":                   {Type: livenote.Fatal},
+		"ERR[unclosed: message":   {Type: livenote.Info, Message: "ERR[unclosed: message"},
+		"ER":                      {Type: livenote.Info, Message: "ER"},
+		"ERRmissing delimiter":    {Type: livenote.Info, Message: "ERRmissing delimiter"},
+		"application message":     {Type: livenote.Info, Message: "application message"},
+		"INF[pfx]:no space":       {Type: livenote.Info, Message: "INF[pfx]:no space"},
+		"INF[pfx]: two: colons: ": {Type: livenote.Info, Prefix: "pfx", Message: "two: colons: "},
+	} {
+		if got := ParseMessage(msg); got != want {
+			t.Errorf("%q: expected %+v, got %+v", msg, want, got)
+		}
+	}
+}
+
+func FuzzParseMessage(f *testing.F) {
+	f.Add(0, "", "message")
+	f.Add(1, "prefix", "a message: with colons")
+	f.Add(2, "a]b", "")
+	f.Add(4, "[", "]: ")
+	f.Add(math.MinInt, "", "min")
+	f.Add(5, "", "untyped message")
+	types := []livenote.NoteType{livenote.Info, livenote.Warn, livenote.Error, livenote.Fatal, livenote.Success, livenote.App}
+	f.Fuzz(func(t *testing.T, typ int, prefix, msg string) {
+		// Arbitrary input must never panic
+		ParseMessage(msg)
+		ParseMessage(prefix + msg)
+
+		if strings.Contains(prefix, "]"+livenote.DelimMsgType) {
+			t.Skip("prefix cannot be told apart from the message")
+		}
+		note := livenote.LiveNoteInfo{
+			Type:    types[uint(typ)%uint(len(types))],
+			Prefix:  prefix,
+			Message: msg,
+		}
+		if note.Type == livenote.App {
+			// Application messages have no type nor prefix, so they are kept whole as info messages
+			got := ParseMessage(msg)
+			if got.Message != msg {
+				t.Skip("application message cannot be told apart from a typed message")
+			}
+			if got != (livenote.LiveNoteInfo{Type: livenote.Info, Message: msg}) {
+				t.Errorf("application message %q: expected an info message, got %+v", msg, got)
+			}
+			return
+		}
+		if got := ParseMessage(note.ToString()); got != note {
+			t.Errorf("round trip of %q: expected %+v, got %+v", note.ToString(), note, got)
+		}
+	})
+}
+
+func TestMessageEntries(t *testing.T) {
+	res := InitResult()
+	res.AddErrorCode("duplicate_key", "email", "email %s is taken", "a@b.c")
+	res.AddWarningCode("weak_password", "password", "password is weak")
+	res.AddEntry(MessageEntry{Code: "not_found", Message: "role not found", Details: map[string]any{"role": "admin"}})
+	if len(res.Messages) != 3 || len(res.Entries) != 3 {
+		t.Fatalf("expected 3 messages and entries, got %d and %d", len(res.Messages), len(res.Entries))
+	}
+	if !res.HasCode("duplicate_key") || res.HasCode("other") {
+		t.Error("unexpected code lookup")
+	}
+	if e, _ := res.EntryByCode("not_found"); e.Severity != SeverityError || e.Details["role"] != "admin" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if es := res.EntriesByField("password"); len(es) != 1 || es[0].Severity != SeverityWarning {
+		t.Errorf("unexpected entries %+v", es)
+	}
+
+	res2 := InitResult()
+	res2.Stuff(res)
+	if !res2.HasCode("weak_password") {
+		t.Error("expected the entries to be stuffed")
+	}
+}
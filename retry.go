@@ -0,0 +1,188 @@
+package stdutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type (
+	// RetryPolicy describes how failed requests are retried
+	RetryPolicy struct {
+		MaxAttempts        int                  // Maximum number of attempts, including the first one. Default: 3
+		BaseBackoff        time.Duration        // Backoff before the first retry. It doubles on every retry. Default: 100ms
+		MaxBackoff         time.Duration        // Maximum backoff between attempts. Default: 5s
+		MaxRetryAfter      time.Duration        // Maximum Retry-After delay waited for. A longer delay stops the retries. Default: 30s
+		Jitter             float64              // Fraction of the backoff (0 to 1) that is randomly added or subtracted. Default: 0
+		RetryStatusCodes   []int                // Status codes that are retried. Default: 408, 429, 502, 503 and 504
+		RetryNetworkError  func(err error) bool // Checks if a network error is retried. Default: IsTransientError
+		RetryNonIdempotent bool                 // Allow retrying non-idempotent verbs (POST and PATCH)
+	}
+)
+
+// defaultRetryStatusCodes are the status codes retried when RetryPolicy.RetryStatusCodes is not set
+var defaultRetryStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// Retry sets the retry policy of the request as an option
+//
+// This is used with <REST verb>Api functions
+func Retry(policy RetryPolicy) RequestOption {
+	return func(rp *RequestParam) error {
+		if policy.MaxAttempts < 0 {
+			return errors.New(`retry: max attempts must not be negative`)
+		}
+		if policy.Jitter < 0 || policy.Jitter > 1 {
+			return errors.New(`retry: jitter must be between 0 and 1`)
+		}
+		rp.Retry = &policy
+		return nil
+	}
+}
+
+// IsTransientError checks if a network error is likely to go away on a retry.
+// Timeouts, connection resets, refused connections and unexpected EOFs are transient.
+// Errors caused by the cancellation of the context are not.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrRequestCanceled) ||
+		errors.Is(err, ErrRequestDeadline) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return false
+}
+
+// next checks if another attempt should be made after the error, and how long to wait for it
+func (p *RetryPolicy) next(method string, attempts int, err error) (time.Duration, bool) {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = 3
+	}
+	if attempts >= maxAttempts {
+		return 0, false
+	}
+	switch strings.ToUpper(method) {
+	case "POST", "PATCH":
+		if !p.RetryNonIdempotent {
+			return 0, false
+		}
+	}
+	wait := p.backoff(attempts)
+	var herr *HTTPError
+	if errors.As(err, &herr) {
+		codes := p.RetryStatusCodes
+		if len(codes) == 0 {
+			codes = defaultRetryStatusCodes
+		}
+		if !In(herr.StatusCode, codes...) {
+			return 0, false
+		}
+		if ra, ok := parseRetryAfter(herr.Header.Get("Retry-After")); ok && ra > wait {
+			maxra := p.MaxRetryAfter
+			if maxra <= 0 {
+				maxra = 30 * time.Second
+			}
+			if ra > maxra {
+				return 0, false
+			}
+			wait = ra
+		}
+		return wait, true
+	}
+	isRetryable := p.RetryNetworkError
+	if isRetryable == nil {
+		isRetryable = IsTransientError
+	}
+	if !isRetryable(err) {
+		return 0, false
+	}
+	return wait, true
+}
+
+// backoff computes the exponential backoff with jitter after the number of attempts made
+func (p *RetryPolicy) backoff(attempts int) time.Duration {
+	base, maxb := p.BaseBackoff, p.MaxBackoff
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	if maxb <= 0 {
+		maxb = 5 * time.Second
+	}
+	wait := base
+	for i := 1; i < attempts && wait < maxb; i++ {
+		wait *= 2
+	}
+	if wait > maxb {
+		wait = maxb
+	}
+	if p.Jitter > 0 {
+		delta := float64(wait) * p.Jitter
+		wait = time.Duration(float64(wait) - delta + rand.Float64()*2*delta)
+	}
+	return wait
+}
+
+// parseRetryAfter parses the Retry-After header in either delay seconds or HTTP date format
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		wait := time.Until(at)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// sleepCtx waits for the duration or until the context is done
+func sleepCtx(ctx context.Context, wait time.Duration) error {
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	tmr := time.NewTimer(wait)
+	defer tmr.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-tmr.C:
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+package stdutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"OK","messages":[],"data":"done"}`))
+	}))
+	defer srv.Close()
+
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		BaseBackoff: time.Millisecond,
+		Jitter:      0.5,
+	}
+	rd := ExecuteJsonApi("GET", srv.URL, nil, false, nil, 5, nil, Retry(policy))
+	if !rd.OK() {
+		t.Fatalf("expected OK, got %s %v", rd.Status, rd.Messages)
+	}
+	if rd.Attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", rd.Attempts)
+	}
+
+	// POST is not retried unless allowed
+	atomic.StoreInt32(&calls, 0)
+	rd = ExecuteJsonApi("POST", srv.URL, []byte(`{}`), false, nil, 5, nil, Retry(policy))
+	if !rd.Error() || rd.Attempts != 1 {
+		t.Errorf("expected a single failed attempt, got %s after %d", rd.Status, rd.Attempts)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	policy.RetryNonIdempotent = true
+	res := CreateApi[string](srv.URL, struct{}{}, Retry(policy))
+	if !res.OK() || res.Data != "done" {
+		t.Errorf("expected OK, got %s %v", res.Status, res.Messages)
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	rd := ExecuteJsonApi("GET", srv.URL, nil, false, nil, 5, nil, Retry(RetryPolicy{BaseBackoff: time.Millisecond}))
+	if rd.Attempts != 1 || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expected no retries, got %d attempts", rd.Attempts)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{
+		BaseBackoff: 100 * time.Millisecond,
+		MaxBackoff:  time.Second,
+	}
+	for attempts, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		8: time.Second,
+	} {
+		if got := p.backoff(attempts); got != want {
+			t.Errorf("backoff(%d): expected %s, got %s", attempts, want, got)
+		}
+	}
+	if d, ok := parseRetryAfter("2"); !ok || d != 2*time.Second {
+		t.Errorf("unexpected Retry-After delay %s", d)
+	}
+	if _, ok := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); !ok {
+		t.Errorf("Retry-After date not parsed")
+	}
+}
+
+func TestRetryAfterLimit(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	rd := ExecuteJsonApi("GET", srv.URL, nil, false, nil, 5, nil, Retry(RetryPolicy{BaseBackoff: time.Millisecond}))
+	if rd.Attempts != 1 || atomic.LoadInt32(&calls) != 1 || time.Since(start) > time.Second {
+		t.Errorf("expected no retries past the maximum Retry-After, got %d attempts in %s", rd.Attempts, time.Since(start))
+	}
+
+	p := RetryPolicy{BaseBackoff: time.Millisecond, MaxRetryAfter: 3 * time.Second}
+	herr := &HTTPError{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"2"}}}
+	if wait, ok := p.next("GET", 1, herr); !ok || wait != 2*time.Second {
+		t.Errorf("expected a 2s wait, got %s %v", wait, ok)
+	}
+}
@@ -0,0 +1,177 @@
+package stdutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ExecuteApiStream sends a streamed request body and returns the streamed response body.
+// The response body is decompressed on the fly. The caller must close the returned reader.
+//
+// The timeout of the request covers reading the response body. Use a context without a
+// deadline and TimeOut(-1) to read large responses without a time limit.
+//
+// A retry policy is only honoured if the body is nil or implements io.Seeker,
+// since the body needs to be sent again on every attempt. Compressed bodies must
+// also have a Len method, as other bodies are read while they are compressed.
+func ExecuteApiStream(ctx context.Context, method string, endPoint string, body io.Reader, opts ...RequestOption) (io.ReadCloser, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	rp := RequestParam{}
+	if err := rp.apply(opts); err != nil {
+		return nil, err
+	}
+	return executeApiStream(ctx, method, endPoint, body, &rp)
+}
+
+// executeApiStream executes the request described by the request parameters and returns the decoded response body
+func executeApiStream(ctx context.Context, method string, endPoint string, body io.Reader, rp *RequestParam) (io.ReadCloser, error) {
+	// A body read by the compressing goroutine of a previous attempt cannot be rewound safely
+	sk, seekable := body.(io.Seeker)
+	if body != nil && (!seekable || streamsCompressed(method, body, rp)) {
+		rp.Retry = nil
+	}
+	var rc io.ReadCloser
+	err := retryRequest(ctx, method, rp, func() error {
+		if seekable && rp.attempts > 1 {
+			if _, err := sk.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		resp, err := doRequest(ctx, method, endPoint, body, rp)
+		if err != nil {
+			return err
+		}
+		if rc, err = decodeBody(resp); err != nil {
+			resp.Body.Close()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// ReadApiStream retrieves a JSON array on an API endpoint and decodes it element by element.
+// Every element is passed to the callback as it is decoded. The array can be the whole
+// response, or the data of a result structure. Returning an error from the callback stops
+// the decoding and adds the error to the result.
+func ReadApiStream[T any](ctx context.Context, url string, fn func(elem T) error, opts ...RequestOption) Result {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	res := InitResult()
+	rp := RequestParam{
+		Compressed: true,
+	}
+	if err := rp.apply(opts); err != nil {
+		res.AddErr(err)
+		return res
+	}
+	rc, err := executeApiStream(ctx, "GET", url, nil, &rp)
+	if err != nil {
+		var herr *HTTPError
+		if errors.As(err, &herr) {
+			rd := ResultData{Result: res}
+			rd.assignHTTPError(herr)
+			return rd.Result
+		}
+		res.AddErr(err)
+		return res
+	}
+	defer rc.Close()
+	if err = decodeStream(json.NewDecoder(rc), &res, fn); err != nil {
+		res.Return(EXCEPTION)
+		res.AddErr(contextError(ctx, err))
+	}
+	return res
+}
+
+// decodeStream decodes a JSON array, or a result structure with a JSON array data, calling fn for every element
+func decodeStream[T any](dec *json.Decoder, res *Result, fn func(elem T) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			res.Return(OK)
+			return nil
+		}
+		return err
+	}
+	switch tok {
+	case json.Delim('['):
+		if err = decodeArray(dec, fn); err != nil {
+			return err
+		}
+		res.Return(OK)
+		return nil
+	case json.Delim('{'):
+	default:
+		return fmt.Errorf("stream: unexpected token %v", tok)
+	}
+
+	// Result structure: collect everything except the data to unmarshal later
+	fields := make(map[string]json.RawMessage)
+	for dec.More() {
+		if tok, err = dec.Token(); err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		if key != "data" {
+			var raw json.RawMessage
+			if err = dec.Decode(&raw); err != nil {
+				return err
+			}
+			fields[key] = raw
+			continue
+		}
+		if tok, err = dec.Token(); err != nil {
+			return err
+		}
+		switch tok {
+		case nil:
+			continue
+		case json.Delim('['):
+			if err = decodeArray(dec, fn); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("stream: data is not an array")
+		}
+	}
+	if _, err = dec.Token(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	trd := ResultData{}
+	if err = json.Unmarshal(b, &trd); err != nil {
+		return err
+	}
+	rd := ResultData{Result: *res}
+	rd.assign(&trd)
+	*res = rd.Result
+	return nil
+}
+
+// decodeArray decodes the elements of an opened JSON array up to its closing bracket
+func decodeArray[T any](dec *json.Decoder, fn func(elem T) error) error {
+	for dec.More() {
+		var elem T
+		if err := dec.Decode(&elem); err != nil {
+			return err
+		}
+		if err := fn(elem); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
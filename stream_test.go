@@ -0,0 +1,102 @@
+package stdutil
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadApiStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var out io.Writer = w
+		if r.URL.Path == "/gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			gzw := gzip.NewWriter(w)
+			defer gzw.Close()
+			out = gzw
+		}
+		switch r.URL.Path {
+		case "/bare":
+			fmt.Fprint(out, `[1,2,3]`)
+		default:
+			fmt.Fprint(out, `{"status":"OK","messages":["WRN: streamed"],"data":[`)
+			for i := 1; i <= 1000; i++ {
+				if i > 1 {
+					fmt.Fprint(out, ",")
+				}
+				fmt.Fprint(out, i)
+			}
+			fmt.Fprint(out, `],"page":1}`)
+		}
+	}))
+	defer srv.Close()
+
+	for _, path := range []string{"/bare", "/envelope", "/gzip"} {
+		sum := 0
+		res := ReadApiStream(context.Background(), srv.URL+path, func(n int) error {
+			sum += n
+			return nil
+		})
+		if !res.OK() {
+			t.Fatalf("%s: unexpected result %s %v", path, res.Status, res.Messages)
+		}
+		if path == "/bare" && sum != 6 {
+			t.Errorf("%s: unexpected sum %d", path, sum)
+		}
+		if path != "/bare" && (sum != 500500 || res.Page == nil || len(res.Messages) != 1) {
+			t.Errorf("%s: unexpected sum %d or result %v", path, sum, res.Messages)
+		}
+	}
+
+	stop := errors.New("stop")
+	cnt := 0
+	res := ReadApiStream(context.Background(), srv.URL+"/envelope", func(n int) error {
+		if cnt++; cnt == 10 {
+			return stop
+		}
+		return nil
+	})
+	if !res.Error() || cnt != 10 {
+		t.Errorf("expected the stream to stop with an error, got %s after %d", res.Status, cnt)
+	}
+}
+
+func TestExecuteApiStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer srv.Close()
+
+	rc, err := ExecuteApiStream(context.Background(), "POST", srv.URL, strings.NewReader("echo this"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, _ := io.ReadAll(rc)
+	if string(b) != "echo this" {
+		t.Errorf("unexpected body %q", b)
+	}
+}
+
+func TestExecuteApiStreamCompressedRetry(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	// A seekable body without a length is compressed as it is read, so it is not retried
+	body := struct{ io.ReadSeeker }{strings.NewReader(strings.Repeat("x", 4096))}
+	_, err := ExecuteApiStream(context.Background(), "PUT", srv.URL, body, Compressed(true), Retry(RetryPolicy{BaseBackoff: time.Millisecond}))
+	if err == nil || calls != 1 {
+		t.Errorf("expected a single attempt, got %d (%v)", calls, err)
+	}
+}
@@ -0,0 +1,132 @@
+package stdutil
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type (
+	// StatusReporter is a result with a status, such as *Result, *ResultAny and *ResultData
+	StatusReporter interface {
+		ResultStatus() Status
+	}
+	// ResultWriter writes results as JSON responses
+	ResultWriter struct {
+		StatusCodes       map[Status]int // HTTP status codes of the result statuses
+		DefaultStatusCode int            // HTTP status code of statuses not in StatusCodes. Default: 200
+		Pretty            bool           // Indent the JSON response
+		GzipMinSize       int            // Size of the response in bytes below which it is not gzipped. Default: 1 KB
+	}
+)
+
+// DefaultResultWriter is the result writer used by WriteResult. Change its
+// status code table to alter the HTTP status codes of the result statuses.
+var DefaultResultWriter = &ResultWriter{
+	StatusCodes: map[Status]int{
+		OK:        http.StatusOK,
+		VALID:     http.StatusOK,
+		YES:       http.StatusOK,
+		NO:        http.StatusOK,
+		INVALID:   http.StatusUnprocessableEntity,
+		EXCEPTION: http.StatusInternalServerError,
+	},
+}
+
+// WriteResult writes a result as a JSON response with the DefaultResultWriter.
+// The HTTP status code is mapped from the status of the result.
+// The response is gzipped if the request accepts it.
+func WriteResult(w http.ResponseWriter, r *http.Request, result StatusReporter) error {
+	return DefaultResultWriter.Write(w, r, result)
+}
+
+// StatusCode returns the HTTP status code of a result status
+func (rw *ResultWriter) StatusCode(status Status) int {
+	if code, ok := rw.StatusCodes[status]; ok {
+		return code
+	}
+	if rw.DefaultStatusCode != 0 {
+		return rw.DefaultStatusCode
+	}
+	return http.StatusOK
+}
+
+// Write writes a result as a JSON response. The HTTP status code is mapped from the status of the result.
+// The response is gzipped if the request accepts it, and the body is omitted for HEAD requests.
+func (rw *ResultWriter) Write(w http.ResponseWriter, r *http.Request, result StatusReporter) error {
+	var (
+		b   []byte
+		err error
+	)
+	if rw.Pretty {
+		b, err = json.MarshalIndent(result, "", "  ")
+	} else {
+		b, err = json.Marshal(result)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	hdr := w.Header()
+	hdr.Set("Content-Type", "application/json; charset=utf-8")
+	code := rw.StatusCode(result.ResultStatus())
+	minSize := rw.GzipMinSize
+	if minSize <= 0 {
+		minSize = defaultCompressMinSize
+	}
+	if len(b) >= minSize {
+		hdr.Add("Vary", "Accept-Encoding")
+	}
+	if r != nil && r.Method == "HEAD" {
+		w.WriteHeader(code)
+		return nil
+	}
+	if r == nil || len(b) < minSize || !acceptsGzip(r.Header) {
+		hdr.Set("Content-Length", strconv.Itoa(len(b)))
+		w.WriteHeader(code)
+		_, err = w.Write(b)
+		return err
+	}
+	hdr.Set("Content-Encoding", "gzip")
+	hdr.Del("Content-Length")
+	w.WriteHeader(code)
+	gzw := gzip.NewWriter(w)
+	if _, err = gzw.Write(b); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
+
+// acceptsGzip checks if the Accept-Encoding header of a request accepts gzip.
+// An explicit gzip coding takes precedence over the * wildcard.
+func acceptsGzip(hdr http.Header) bool {
+	wildcard := false
+	for _, v := range hdr.Values("Accept-Encoding") {
+		for _, enc := range strings.Split(v, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+			name = strings.TrimSpace(name)
+			switch {
+			case strings.EqualFold(name, "gzip"):
+				return acceptedQuality(params)
+			case name == "*":
+				wildcard = acceptedQuality(params)
+			}
+		}
+	}
+	return wildcard
+}
+
+// acceptedQuality checks if the parameters of a coding of the Accept-Encoding header
+// do not set a quality value of 0, which means the coding is not acceptable
+func acceptedQuality(params string) bool {
+	for _, p := range strings.Split(params, ";") {
+		if q, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+			if f, err := strconv.ParseFloat(q, 64); err == nil && f == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
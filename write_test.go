@@ -0,0 +1,146 @@
+package stdutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteResult(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			res := ResultAny[[]string]{Result: InitResult(), Data: []string{strings.Repeat("x", 2000)}}
+			res.Return(OK)
+			WriteResult(w, r, &res)
+		case "/invalid":
+			res := InitResult()
+			res.Return(INVALID)
+			res.AddWarning("name is required")
+			WriteResult(w, r, &res)
+		case "/exception":
+			res := InitResult()
+			res.AddError("database is down")
+			WriteResult(w, r, &res)
+		case "/pretty":
+			rw := ResultWriter{Pretty: true}
+			res := InitResult(NameValue[string]{Name: "status", Value: "OK"})
+			rw.Write(w, r, &res)
+		}
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{"/ok", http.StatusOK},
+		{"/invalid", http.StatusUnprocessableEntity},
+		{"/exception", http.StatusInternalServerError},
+	} {
+		resp, err := http.Get(srv.URL + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.code {
+			t.Errorf("%s: expected %d, got %d", tc.path, tc.code, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%s: unexpected content type %s", tc.path, ct)
+		}
+	}
+
+	// The client side reads the results back, with the body gzipped
+	var enc string
+	res := ReadApi[[]string](srv.URL+"/ok", Intercept(func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			resp, err := next(req)
+			if err == nil {
+				enc = resp.Header.Get("Content-Encoding")
+			}
+			return resp, err
+		}
+	}))
+	if !res.OK() || len(res.Data) != 1 || enc != "gzip" {
+		t.Errorf("unexpected result %s %d %q", res.Status, len(res.Data), enc)
+	}
+	rd := ExecuteJsonApi("GET", srv.URL+"/exception", nil, false, nil, 5, nil)
+	if rd.OK() || !strings.Contains(rd.MessagesToString(), "database is down") {
+		t.Errorf("unexpected result %s %s", rd.Status, rd.MessagesToString())
+	}
+
+	resp, err := http.Get(srv.URL + "/pretty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b := make([]byte, 64)
+	n, _ := resp.Body.Read(b)
+	if !strings.Contains(string(b[:n]), "\n  ") {
+		t.Errorf("expected an indented response, got %s", b[:n])
+	}
+}
+
+func TestWriteResultRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := InitResult()
+		switch r.URL.Path {
+		case "/invalid":
+			res.Return(INVALID)
+			res.AddWarning("name is required")
+		case "/no":
+			res.Return(NO)
+			rw := ResultWriter{StatusCodes: map[Status]int{NO: http.StatusNotFound}}
+			rw.Write(w, r, &res)
+			return
+		case "/exception":
+			res.AddError("database is down")
+		case "/nostatus":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"messages":["WRN: name is required"]}`))
+			return
+		}
+		WriteResult(w, r, &res)
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		path   string
+		status Status
+		msgs   int
+	}{
+		{"/invalid", INVALID, 1},
+		{"/no", NO, 0},
+		{"/exception", EXCEPTION, 1},
+		{"/nostatus", EXCEPTION, 2}, // the warning and the HTTP error
+	} {
+		res := ReadApi[any](srv.URL + tc.path)
+		if res.Status != string(tc.status) || len(res.Messages) != tc.msgs {
+			t.Errorf("%s: unexpected result %s %v", tc.path, res.Status, res.Messages)
+		}
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for ae, want := range map[string]bool{
+		"":                    false,
+		"gzip":                true,
+		"deflate, GZIP;q=0.5": true,
+		"gzip;q=0":            false,
+		"*":                   true,
+		"*;q=0":               false,
+		"*;q=0, gzip":         true,
+		"gzip;q=0, *":         false,
+		"br, *;q=0":           false,
+	} {
+		hdr := http.Header{}
+		if ae != "" {
+			hdr.Set("Accept-Encoding", ae)
+		}
+		if got := acceptsGzip(hdr); got != want {
+			t.Errorf("%q: expected %v, got %v", ae, want, got)
+		}
+	}
+}